@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Collations accepted after a name, version or ext sort key, e.g. "name:fold".
+//
+//	fold   - case-insensitive, so "apple" < "Zebra"
+//	norm   - insensitive to Unicode normalization (NFC vs NFD)
+//	locale - collation rules of the LC_COLLATE locale
+var CollationNames = []string{"fold", "norm", "locale"}
+
+// collation returns a three-way string comparison for the given collation.
+// The returned function is not safe for concurrent use.
+func collation(name string) func(a, b string) int {
+	switch name {
+	case "fold":
+		caser := cases.Fold()
+		return func(a, b string) int {
+			return strings.Compare(caser.String(norm.NFC.String(a)), caser.String(norm.NFC.String(b)))
+		}
+	case "norm":
+		return func(a, b string) int {
+			return strings.Compare(norm.NFC.String(a), norm.NFC.String(b))
+		}
+	case "locale":
+		tag, ok := collateLocale()
+		if !ok {
+			return strings.Compare
+		}
+		return collate.New(tag).CompareString
+	}
+	return strings.Compare
+}
+
+// collateLocale returns the language of the locale used for collation,
+// following the POSIX precedence of LC_ALL, LC_COLLATE and LANG. It returns
+// false for the "C" and "POSIX" locales, which collate bytewise.
+func collateLocale() (language.Tag, bool) {
+	var locale string
+	for _, env := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if locale = os.Getenv(env); locale != "" {
+			break
+		}
+	}
+	// e.g. "de_DE.UTF-8@euro"
+	if i := strings.IndexAny(locale, ".@"); i != -1 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return language.Und, false
+	}
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return language.Und, false
+	}
+	return tag, true
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column is a piece of metadata about the nodes: shown between brackets
+// before their names in the text output, as an attribute of the JSON and
+// XML ones, and as a column of the table one.
+type Column interface {
+	// Name identifies the column in --columns and in the outputs.
+	Name() string
+	// Value returns the value of the column for the node, and false when it
+	// has none. It is called on the nodes that could not be stat'ed too,
+	// whose FileInfo is nil.
+	Value(opts *Options, node *Node) (string, bool)
+}
+
+// TextColumn is implemented by the columns formatted otherwise in the text
+// output, such as padded to line up.
+type TextColumn interface {
+	Column
+	Text(opts *Options, node *Node) (string, bool)
+}
+
+// funcColumn is a Column made of functions, as the built-in ones are.
+type funcColumn struct {
+	name  string
+	value func(opts *Options, node *Node) (string, bool)
+	// text defaults to value
+	text func(opts *Options, node *Node) (string, bool)
+}
+
+func (c *funcColumn) Name() string { return c.name }
+
+func (c *funcColumn) Value(opts *Options, node *Node) (string, bool) {
+	return c.value(opts, node)
+}
+
+func (c *funcColumn) Text(opts *Options, node *Node) (string, bool) {
+	if c.text == nil {
+		return c.value(opts, node)
+	}
+	return c.text(opts, node)
+}
+
+// NewColumn returns a column named name, whose values are given by value.
+func NewColumn(name string, value func(opts *Options, node *Node) (string, bool)) Column {
+	return &funcColumn{name: name, value: value}
+}
+
+// stated wraps the value of a column known only for the nodes that could
+// be stat'ed.
+func stated(f func(opts *Options, node *Node) (string, bool)) func(*Options, *Node) (string, bool) {
+	return func(opts *Options, node *Node) (string, bool) {
+		if node.FileInfo == nil {
+			return "", false
+		}
+		return f(opts, node)
+	}
+}
+
+// statColumn returns the value of a column from the stat of the node.
+func statColumn(f func(inode, device, uid, gid uint64) string) func(*Options, *Node) (string, bool) {
+	return stated(func(_ *Options, node *Node) (string, bool) {
+		if ok, inode, device, uid, gid := getStat(node); ok {
+			return f(inode, device, uid, gid), true
+		}
+		return "", false
+	})
+}
+
+// fileText returns the text of a column shown on files only, formatted by f.
+func fileText(c func(*Options, *Node) (string, bool), f func(string) string) func(*Options, *Node) (string, bool) {
+	return func(opts *Options, node *Node) (string, bool) {
+		if node.FileInfo == nil || node.IsDir() {
+			return "", false
+		}
+		v, ok := c(opts, node)
+		return f(v), ok
+	}
+}
+
+func padLeft(n int) func(string) string {
+	return func(s string) string { return fmt.Sprintf("%*s", n, s) }
+}
+
+func padRight(n int) func(string) string {
+	return func(s string) string { return fmt.Sprintf("%-*s", n, s) }
+}
+
+func nodeSize(opts *Options, node *Node) (string, bool) {
+	size := node.Size()
+	if node.IsDir() {
+		size, _ = dirRecursiveSize(opts, node)
+	}
+	return strconv.FormatInt(size, 10), true
+}
+
+func sizeText(opts *Options, node *Node) (string, bool) {
+	if node.FileInfo == nil {
+		return "", false
+	}
+	size := node.Size()
+	if node.IsDir() {
+		var err error
+		if size, err = dirRecursiveSize(opts, node); err != nil && size <= 0 {
+			if opts.UnitSize {
+				return "    ", true
+			}
+			return "           ", true
+		}
+	}
+	if opts.UnitSize {
+		return fmt.Sprintf("%4s", formatBytes(size)), true
+	}
+	return fmt.Sprintf("%11d", size), true
+}
+
+func mtimeText(opts *Options, node *Node) (string, bool) {
+	if node.FileInfo == nil || node.IsDir() {
+		return "", false
+	}
+	t := opts.Now
+	if t.IsZero() {
+		t = time.Now()
+	}
+	format := "Jan 02 15:04"
+	if node.ModTime().Year() != t.Year() {
+		format = "Jan 02  2006"
+	}
+	return node.ModTime().Format(format), true
+}
+
+var (
+	columnsByName = map[string]Column{}
+	columnNames   []string
+)
+
+func init() {
+	for _, c := range []*funcColumn{
+		{name: "path", value: func(_ *Options, node *Node) (string, bool) { return node.path, true }},
+		{name: "depth", value: func(_ *Options, node *Node) (string, bool) { return strconv.Itoa(node.depth), true }},
+		{name: "type", value: func(_ *Options, node *Node) (string, bool) { return node.Type(), true }},
+		{name: "size", value: stated(nodeSize), text: sizeText},
+		{name: "mode", value: stated(func(_ *Options, node *Node) (string, bool) {
+			return fmt.Sprintf("%04o", unixMode(node.Mode())), true
+		})},
+		{name: "prot", value: stated(func(_ *Options, node *Node) (string, bool) {
+			return node.Mode().String(), true
+		})},
+		{name: "uid", value: statColumn(func(_, _, uid, _ uint64) string { return strconv.FormatUint(uid, 10) })},
+		{name: "user", value: statColumn(func(_, _, uid, _ uint64) string { return userName(uid) })},
+		{name: "gid", value: statColumn(func(_, _, _, gid uint64) string { return strconv.FormatUint(gid, 10) })},
+		{name: "group", value: statColumn(func(_, _, _, gid uint64) string { return groupName(gid) })},
+		{name: "mtime", value: stated(func(_ *Options, node *Node) (string, bool) {
+			return node.ModTime().Format(time.RFC3339), true
+		}), text: mtimeText},
+		{name: "ctime", value: stated(func(_ *Options, node *Node) (string, bool) {
+			t, ok := changeTime(node)
+			return t.Format(time.RFC3339), ok
+		})},
+		{name: "inode", value: statColumn(func(inode, _, _, _ uint64) string { return strconv.FormatUint(inode, 10) })},
+		{name: "device", value: statColumn(func(_, device, _, _ uint64) string { return strconv.FormatUint(device, 10) })},
+		{name: "target", value: stated(func(_ *Options, node *Node) (string, bool) {
+			if node.Mode()&os.ModeSymlink == 0 {
+				return "", false
+			}
+			target, err := os.Readlink(node.path)
+			return target, err == nil
+		})},
+		{name: "line", value: stated(func(_ *Options, node *Node) (string, bool) {
+			if node.IsDir() {
+				return "", false
+			}
+			line, _, ok := node.firstLine()
+			return line, ok
+		})},
+		{name: "error", value: func(_ *Options, node *Node) (string, bool) {
+			text := node.errorText()
+			return text, text != ""
+		}},
+	} {
+		RegisterColumn(c)
+	}
+	// the text output lines up the properties of files
+	for name, f := range map[string]func(string) string{
+		"mode": padLeft(0), "prot": padLeft(0), "uid": padRight(8), "user": padRight(8),
+		"gid": padRight(4), "group": padRight(8), "inode": padLeft(0), "device": padLeft(3),
+	} {
+		c := columnsByName[name].(*funcColumn)
+		c.text = fileText(c.value, f)
+	}
+}
+
+// RegisterColumn makes a column available by name, such as to the
+// --columns option, replacing any column of the same name.
+func RegisterColumn(c Column) {
+	if _, ok := columnsByName[c.Name()]; !ok {
+		columnNames = append(columnNames, c.Name())
+	}
+	columnsByName[c.Name()] = c
+}
+
+// ColumnNames returns the names of the registered columns, the built-in
+// ones first.
+func ColumnNames() []string {
+	return append([]string(nil), columnNames...)
+}
+
+// ParseColumns parses a comma separated list of registered columns.
+func ParseColumns(s string) ([]Column, error) {
+	var columns []Column
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		c, ok := columnsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("column '%s' not valid, should be one of: %s",
+				name, strings.Join(columnNames, ","))
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// flagColumns are the columns shown by the File options when Columns is
+// not set, in the order of the text output.
+var flagColumns = []struct {
+	name string
+	show func(opts *Options) bool
+}{
+	{"inode", func(opts *Options) bool { return opts.Inodes }},
+	{"device", func(opts *Options) bool { return opts.Device }},
+	{"prot", func(opts *Options) bool { return opts.FileMode }},
+	{"mode", func(opts *Options) bool { return opts.FileMode }},
+	{"user", func(opts *Options) bool { return opts.ShowUid }},
+	{"gid", func(opts *Options) bool { return opts.ShowGid }},
+	{"size", func(opts *Options) bool { return opts.ByteSize || opts.UnitSize }},
+	{"mtime", func(opts *Options) bool { return opts.LastMod }},
+	{"git", func(opts *Options) bool { return opts.Git != nil }},
+	{"hash", func(opts *Options) bool { return opts.Hashes != nil && opts.Hashes.sums != nil }},
+	{"dupe", func(opts *Options) bool { return opts.Hashes != nil && opts.Hashes.dupes != nil }},
+	{"links", func(opts *Options) bool { return opts.HardLinks != nil }},
+	{"hardlink", func(opts *Options) bool { return opts.HardLinks != nil }},
+	{"lines", func(opts *Options) bool { return opts.Lines != nil }},
+	{"words", func(opts *Options) bool { return opts.Lines != nil && opts.Lines.Words }},
+	{"nonblank", func(opts *Options) bool { return opts.Lines != nil && opts.Lines.Code }},
+	{"code", func(opts *Options) bool { return opts.Lines != nil && opts.Lines.Code }},
+	{"mount", func(opts *Options) bool { return opts.OneFS || opts.MountTypes != nil }},
+	{"fstype", func(opts *Options) bool { return opts.MountTypes != nil }},
+	{"line", func(opts *Options) bool { return opts.Contents }},
+}
+
+// showColumn tells whether the column is shown: whether it is one of
+// Columns when set, or else whether the File option showing it is set.
+func (opts *Options) showColumn(name string) bool {
+	if opts.Columns != nil {
+		for _, c := range opts.Columns {
+			if c.Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+	for _, fc := range flagColumns {
+		if fc.name == name {
+			return fc.show(opts)
+		}
+	}
+	return false
+}
+
+// columns returns the columns shown: Columns when set, or else those of
+// the File options.
+func (opts *Options) columns() []Column {
+	if opts.Columns != nil {
+		return opts.Columns
+	}
+	var columns []Column
+	for _, fc := range flagColumns {
+		if fc.show(opts) {
+			columns = append(columns, columnsByName[fc.name])
+		}
+	}
+	return columns
+}
+
+// textColumns returns the columns shown between brackets in the text
+// output, where the mode is shown as its string, and the first line, the
+// target of hard links and mount points after the name, unless asked for
+// in Columns.
+func (opts *Options) textColumns() []Column {
+	if opts.Columns != nil {
+		return opts.Columns
+	}
+	var columns []Column
+	for _, c := range opts.columns() {
+		switch c.Name() {
+		case "mode", "line", "hardlink", "mount", "fstype":
+		default:
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
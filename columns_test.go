@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestColumns(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 5},
+			{name: "b", size: 10},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	labels := map[string]string{"root/a": "draft"}
+	RegisterColumn(NewColumn("label", func(_ *Options, node *Node) (string, bool) {
+		label, ok := labels[node.path]
+		return label, ok
+	}))
+	columns, err := ParseColumns("label,size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b, Columns: columns}
+	tr.Visit(opts)
+
+	var tests = []struct {
+		name     string
+		print    func() error
+		expected string
+	}{
+		{"text", func() error { return Render(opts, tr, NewTextRenderer(opts)) }, `[         15]  root
+├── [draft           5]  a
+└── [         10]  b
+`},
+		{"json", func() error { return PrintJSON(opts, Nodes{tr}, nil) }, `[
+  {
+    "type": "directory",
+    "name": "root",
+    "size": 15,
+    "contents": [
+      {
+        "type": "file",
+        "name": "a",
+        "size": 5,
+        "columns": {
+          "label": "draft"
+        }
+      },
+      {
+        "type": "file",
+        "name": "b",
+        "size": 10
+      }
+    ]
+  }
+]
+`},
+		{"xml", func() error { return PrintXML(opts, Nodes{tr}, nil) }, `<?xml version="1.0" encoding="UTF-8"?>
+<tree>
+  <directory name="root" size="15">
+    <file name="a" size="5" label="draft"></file>
+    <file name="b" size="10"></file>
+  </directory>
+</tree>
+`},
+		{"csv", func() error { return PrintTable(opts, "csv", columns, true, Nodes{tr}) }, `label,size
+,15
+draft,5
+,10
+`},
+	}
+	for _, test := range tests {
+		b.Reset()
+		if err := test.print(); err != nil {
+			t.Fatal(err)
+		}
+		if actual := b.String(); actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.name, actual, test.expected)
+		}
+	}
+}
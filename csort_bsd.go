@@ -6,6 +6,7 @@ package main
 import (
 	"os"
 	"syscall"
+	"time"
 )
 
 func CTimeSort(f1, f2 os.FileInfo) bool {
@@ -20,3 +21,25 @@ func CTimeSort(f1, f2 os.FileInfo) bool {
 	}
 	return s1.Ctimespec.Sec < s2.Ctimespec.Sec
 }
+
+func ATimeSort(f1, f2 os.FileInfo) bool {
+	if f1 == nil || f2 == nil {
+		return f2 == nil
+	}
+	s1, ok1 := f1.Sys().(*syscall.Stat_t)
+	s2, ok2 := f2.Sys().(*syscall.Stat_t)
+	// If this type of node isn't an os node then revert to ModSort
+	if !ok1 || !ok2 {
+		return ModSort(f1, f2)
+	}
+	return s1.Atimespec.Sec < s2.Atimespec.Sec
+}
+
+// changeTime returns the last status change time of the file, if known.
+func changeTime(fi os.FileInfo) (time.Time, bool) {
+	s, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(s.Ctimespec.Sec), int64(s.Ctimespec.Nsec)), true
+}
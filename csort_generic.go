@@ -3,5 +3,18 @@
 
 package main
 
+import (
+	"os"
+	"time"
+)
+
 // CtimeSort for unsupported OS - just compare ModTime
 var CTimeSort = ModSort
+
+// ATimeSort for unsupported OS - just compare ModTime
+var ATimeSort = ModSort
+
+// changeTime is unknown on unsupported OS
+func changeTime(fi os.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ChangeKind tells how a node differs between two trees.
+type ChangeKind int
+
+const (
+	Unchanged ChangeKind = iota
+	Added
+	Removed
+	Changed
+)
+
+// DiffOptions selects the properties compared by Diff.
+type DiffOptions struct {
+	Size    bool
+	Mode    bool
+	ModTime bool
+	// Hash compares the content of files of the same size
+	Hash bool
+}
+
+// ParseDiffOptions parses a comma-separated list of the properties to
+// compare: size, mode, mtime and hash.
+func ParseDiffOptions(s string) (DiffOptions, error) {
+	var by DiffOptions
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "size":
+			by.Size = true
+		case "mode":
+			by.Mode = true
+		case "mtime":
+			by.ModTime = true
+		case "hash":
+			by.Hash = true
+		case "":
+		default:
+			return by, fmt.Errorf("diff property '%s' not valid, should be one of: size,mode,mtime,hash", name)
+		}
+	}
+	return by, nil
+}
+
+// DiffNode is a node of the merge of two visited trees. Old or New is nil
+// when the node was added or removed.
+type DiffNode struct {
+	Old, New *Node
+	Kind     ChangeKind
+	// Changes describes what changed, e.g. "size 10 → 12"
+	Changes []string
+	nodes   []*DiffNode
+}
+
+// DiffReport counts the nodes of a diff by kind of change.
+type DiffReport struct {
+	Added, Removed, Changed, Unchanged int
+}
+
+// Diff merges the trees before and after, which must have been visited with
+// the same opts.
+func Diff(before, after *Node, opts *Options, by DiffOptions) *DiffNode {
+	d := &DiffNode{Old: before, New: after}
+	switch {
+	case before == nil:
+		d.Kind = Added
+	case after == nil:
+		d.Kind = Removed
+	default:
+		d.Changes = compareNodes(before, after, by)
+		if len(d.Changes) > 0 {
+			d.Kind = Changed
+		}
+	}
+	// merge the children of both nodes, by name
+	var reps Nodes
+	merged := make(map[*Node]*DiffNode)
+	afterByName := make(map[string]*Node)
+	if after != nil {
+		for _, nnode := range after.nodes {
+			afterByName[baseName(nnode)] = nnode
+		}
+	}
+	if before != nil {
+		for _, onode := range before.nodes {
+			nnode := afterByName[baseName(onode)]
+			delete(afterByName, baseName(onode))
+			reps = append(reps, onode)
+			merged[onode] = Diff(onode, nnode, opts, by)
+		}
+	}
+	if after != nil {
+		for _, nnode := range after.nodes {
+			if _, ok := afterByName[baseName(nnode)]; ok {
+				reps = append(reps, nnode)
+				merged[nnode] = Diff(nil, nnode, opts, by)
+			}
+		}
+	}
+	if !opts.NoSort {
+		sort.Stable(&ByKeys{Nodes: reps, Keys: opts.sortKeys()})
+	}
+	for _, rep := range reps {
+		d.nodes = append(d.nodes, merged[rep])
+	}
+	return d
+}
+
+// baseName returns the name of the node, even when its stat failed.
+func baseName(node *Node) string {
+	if node.FileInfo != nil {
+		return node.Name()
+	}
+	i := strings.LastIndexAny(node.path, `/\`)
+	return node.path[i+1:]
+}
+
+func compareNodes(before, after *Node, by DiffOptions) (changes []string) {
+	if before.FileInfo == nil || after.FileInfo == nil {
+		if (before.FileInfo == nil) != (after.FileInfo == nil) {
+			changes = append(changes, "type")
+		}
+		return
+	}
+	if oldType, newType := before.Type(), after.Type(); oldType != newType {
+		return append(changes, fmt.Sprintf("type %s → %s", oldType, newType))
+	}
+	isFile := !before.IsDir()
+	if by.Size && isFile && before.Size() != after.Size() {
+		changes = append(changes, fmt.Sprintf("size %d → %d", before.Size(), after.Size()))
+	}
+	if by.Mode && before.Mode() != after.Mode() {
+		changes = append(changes, fmt.Sprintf("mode %s → %s", before.Mode(), after.Mode()))
+	}
+	if by.ModTime && !before.ModTime().Equal(after.ModTime()) {
+		changes = append(changes, "mtime")
+	}
+	if by.Hash && isFile && before.Size() == after.Size() && before.Mode().IsRegular() {
+		oldSum, err1 := nodeSHA256(before)
+		newSum, err2 := nodeSHA256(after)
+		if err1 == nil && err2 == nil && !bytes.Equal(oldSum, newSum) {
+			changes = append(changes, "content")
+		}
+	}
+	return
+}
+
+func fileSHA256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// node returns the node the DiffNode is printed as.
+func (d *DiffNode) node() *Node {
+	if d.New != nil {
+		return d.New
+	}
+	return d.Old
+}
+
+// errors returns the errors met on either side, such as unreadable
+// directories, whose contents are then missing from the diff.
+func (d *DiffNode) errors() (errs []string) {
+	for _, node := range []*Node{d.Old, d.New} {
+		if node == nil {
+			continue
+		}
+		if text := node.errorText(); text != "" && (len(errs) == 0 || errs[0] != text) {
+			errs = append(errs, text)
+		}
+	}
+	return
+}
+
+// Report counts the descendants of the node by kind of change.
+func (d *DiffNode) Report() (r DiffReport) {
+	for _, nd := range d.nodes {
+		switch nd.Kind {
+		case Added:
+			r.Added++
+		case Removed:
+			r.Removed++
+		case Changed:
+			r.Changed++
+		default:
+			r.Unchanged++
+		}
+		nr := nd.Report()
+		r.Added, r.Removed = r.Added+nr.Added, r.Removed+nr.Removed
+		r.Changed, r.Unchanged = r.Changed+nr.Changed, r.Unchanged+nr.Unchanged
+	}
+	return
+}
+
+func (r DiffReport) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed, %d unchanged",
+		r.Added, r.Removed, r.Changed, r.Unchanged)
+}
+
+// Print the merged tree, marking added, removed and changed nodes.
+func (d *DiffNode) Print(opts *Options) {
+	fmt.Fprintf(opts.OutFile, "--- %s\n+++ %s\n", d.Old.path, d.New.path)
+	d.print("", opts)
+}
+
+var diffMarks = map[ChangeKind]struct{ mark, style string }{
+	Unchanged: {"   ", ""},
+	Added:     {"[+]", "32"},
+	Removed:   {"[-]", "31"},
+	Changed:   {"[~]", "33"},
+}
+
+func (d *DiffNode) print(indent string, opts *Options) {
+	node := d.node()
+	var name string
+	if node.depth == 0 {
+		name = "."
+	} else if opts.FullPath {
+		name = node.path
+	} else {
+		name = baseName(node)
+	}
+	if node.FileInfo != nil && node.IsDir() && node.depth != 0 {
+		name += "/"
+	}
+	if len(d.Changes) > 0 {
+		name += " (" + strings.Join(d.Changes, ", ") + ")"
+	}
+	if errs := d.errors(); len(errs) > 0 {
+		name += " [" + strings.Join(errs, ", ") + "]"
+	}
+	if d.New != nil && d.New.incomplete || d.Old != nil && d.Old.incomplete {
+		name += " [incomplete]"
+	}
+	if node.depth != 0 {
+		mark := diffMarks[d.Kind]
+		name = mark.mark + " " + name
+		if opts.Colorize && mark.style != "" {
+			name = ANSIColorFormat(mark.style, name)
+		}
+	}
+	fmt.Fprintln(opts.OutFile, name)
+
+	add := "│   "
+	for i, nd := range d.nodes {
+		if opts.NoIndent {
+			add = ""
+		} else if i == len(d.nodes)-1 {
+			fmt.Fprint(opts.OutFile, indent+"└── ")
+			add = "    "
+		} else {
+			fmt.Fprint(opts.OutFile, indent+"├── ")
+		}
+		nd.print(indent+add, opts)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	defer out.clear()
+	before := &file{
+		name: "old",
+		files: []*file{
+			{name: "a", size: 10},
+			{name: "b", size: 10},
+			{name: "c", files: []*file{{name: "d", size: 10}}},
+		},
+	}
+	after := &file{
+		name: "new",
+		files: []*file{
+			{name: "a", size: 10},
+			{name: "b", size: 12, mode: 0755},
+			{name: "c", files: []*file{{name: "e", size: 10}}},
+			{name: "f", size: 10},
+		},
+	}
+	fs.clean().addFile(before.name, before).addFile(after.name, after)
+	opts := &Options{Fs: fs, OutFile: out}
+	oldNode, newNode := New(before.name), New(after.name)
+	oldNode.Visit(opts)
+	newNode.Visit(opts)
+	diff := Diff(oldNode, newNode, opts, DiffOptions{Size: true, Mode: true})
+	diff.Print(opts)
+	expected := `--- old
++++ new
+.
+├──     a
+├── [~] b (size 10 → 12, mode ---------- → -rwxr-xr-x)
+├──     c/
+│   ├── [-] d
+│   └── [+] e
+└── [+] f
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%s\nexpected:\n%s", out.str, expected)
+	}
+	expectedReport := DiffReport{Added: 2, Removed: 1, Changed: 1, Unchanged: 2}
+	if report := diff.Report(); report != expectedReport {
+		t.Errorf("got report %v, expected %v", report, expectedReport)
+	}
+}
+
+func TestParseDiffOptions(t *testing.T) {
+	by, err := ParseDiffOptions("size,hash")
+	if err != nil || by != (DiffOptions{Size: true, Hash: true}) {
+		t.Errorf("got %+v (%v)", by, err)
+	}
+	if _, err := ParseDiffOptions("size,owner"); err == nil {
+		t.Errorf("expected an error for an unknown property")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 10},
+			{name: "b", size: 10, mode: 0644},
+			{name: "c", mode: os.ModeDir | 0755, files: []*file{{name: "d", size: 10}}},
+		},
+	}
+	fs.clean().addFile(root.name, root)
+	opts := &Options{Fs: fs, OutFile: out, DeepLevel: 2}
+	saved := New(root.name)
+	saved.Visit(opts)
+	b := new(bytes.Buffer)
+	if err := SaveSnapshot(b, opts, Nodes{saved}, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"directories": 1,`) {
+		t.Errorf("expected the snapshot to count 1 directory:\n%s", b)
+	}
+	trees, depth, err := LoadSnapshot(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 1 || depth != 2 {
+		t.Errorf("expected 1 tree saved with depth 2, got %d and %d", len(trees), depth)
+	}
+	sopts := *opts
+	sopts.Fs = NewEntryFS(trees)
+	by := DiffOptions{Size: true, Mode: true, ModTime: true}
+
+	// unchanged
+	before, after := New(root.name), New(root.name)
+	before.Visit(&sopts)
+	after.Visit(opts)
+	if report := Diff(before, after, opts, by).Report(); report != (DiffReport{Unchanged: 4}) {
+		t.Errorf("expected no change, got %v", report)
+	}
+
+	// drifted
+	root.files[1].mode = 0600
+	root.files[2].files = append(root.files[2].files, &file{name: "e"})
+	fs.clean().addFile(root.name, root)
+	after = New(root.name)
+	after.Visit(opts)
+	if report := Diff(before, after, opts, by).Report(); report != (DiffReport{Added: 1, Changed: 1, Unchanged: 3}) {
+		t.Errorf("expected a change, got %v", report)
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	before := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "locked", files: []*file{{name: "x"}}},
+		},
+	}
+	after := &file{
+		name: "after",
+		files: []*file{
+			{name: "a"},
+			{name: "locked", files: []*file{{name: "x"}}},
+		},
+	}
+	fs := &unreadableFs{NewFs().addFile(before.name, before).addFile(after.name, after), map[string]bool{"after/locked": true}}
+	b := new(bytes.Buffer)
+	opts := &Options{Fs: fs, OutFile: b}
+	beforeNode, afterNode := New(before.name), New(after.name)
+	beforeNode.Visit(opts)
+	afterNode.Visit(opts)
+	Diff(beforeNode, afterNode, opts, DiffOptions{}).Print(opts)
+	expected := `--- root
++++ after
+.
+├──     a
+└──     locked/ [permission denied]
+    └── [-] x
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build solaris
+// +build solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+const _S_IFDOOR = 0xd000
+
+func isDoor(fi os.FileInfo) bool {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && stat.Mode&syscall.S_IFMT == _S_IFDOOR
+}
@@ -0,0 +1,11 @@
+//go:build !solaris
+// +build !solaris
+
+package main
+
+import "os"
+
+// isDoor reports whether fi is a Solaris door, which exist nowhere else.
+func isDoor(fi os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kinds of the errors met while visiting a tree.
+const (
+	// ErrStat is the kind of entries that could not be stat'ed
+	ErrStat = "stat"
+	// ErrUnreadable is the kind of directories that could not be read
+	ErrUnreadable = "unreadable"
+	// ErrBrokenLink is the kind of links whose target does not exist
+	ErrBrokenLink = "broken_link"
+)
+
+// errorKind returns the kind of the error of the node, or "" when there is
+// none.
+func (node *Node) errorKind() string {
+	switch {
+	case node.err != nil && node.FileInfo == nil:
+		return ErrStat
+	case node.err != nil:
+		return ErrUnreadable
+	case node.FileInfo != nil && node.brokenLink:
+		return ErrBrokenLink
+	}
+	return ""
+}
+
+// LinkReader is implemented by filesystems whose links can be read, such as
+// ostree.FS.
+type LinkReader interface {
+	Readlink(path string) (string, error)
+}
+
+// isBrokenLink tells whether the node is a link to nothing, following the
+// links it leads to through Fs. It is only known when Fs is a LinkReader.
+func (opts *Options) isBrokenLink(node *Node) bool {
+	fs, ok := opts.Fs.(LinkReader)
+	if !ok || node.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	path := node.path
+	// as many links as followed by the system
+	for i := 0; i < 40; i++ {
+		target, err := fs.Readlink(path)
+		if err != nil {
+			return false
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		fi, err := opts.Fs.Stat(target)
+		if err != nil {
+			return true
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return false
+		}
+		path = target
+	}
+	return true
+}
+
+// errorText returns the message of the error of the node.
+func (node *Node) errorText() string {
+	if node.err != nil {
+		return errMessage(node.err)
+	}
+	if node.errorKind() == ErrBrokenLink {
+		return "broken link"
+	}
+	return ""
+}
+
+// ErrorCounts counts the errors of a tree by kind.
+type ErrorCounts struct {
+	Unreadable   int `json:"unreadable" xml:"unreadable"`
+	BrokenLinks  int `json:"broken_links" xml:"broken_links"`
+	StatFailures int `json:"stat_failures" xml:"stat_failures"`
+}
+
+// Add counts the errors of the node and its children.
+func (c *ErrorCounts) Add(node *Node) {
+	c.count(node.errorKind())
+	for _, nnode := range node.nodes {
+		c.Add(nnode)
+	}
+}
+
+func (c *ErrorCounts) count(kind string) {
+	switch kind {
+	case ErrStat:
+		c.StatFailures++
+	case ErrUnreadable:
+		c.Unreadable++
+	case ErrBrokenLink:
+		c.BrokenLinks++
+	}
+}
+
+// Total returns the number of errors.
+func (c *ErrorCounts) Total() int {
+	return c.Unreadable + c.BrokenLinks + c.StatFailures
+}
+
+// String returns the summary of the errors printed in the footer.
+func (c *ErrorCounts) String() string {
+	plural := func(n int, one, many string) string {
+		if n == 1 {
+			return "1 " + one
+		}
+		return fmt.Sprintf("%d %s", n, many)
+	}
+	var kinds []string
+	if c.Unreadable > 0 {
+		kinds = append(kinds, plural(c.Unreadable, "unreadable directory", "unreadable directories"))
+	}
+	if c.BrokenLinks > 0 {
+		kinds = append(kinds, plural(c.BrokenLinks, "broken link", "broken links"))
+	}
+	if c.StatFailures > 0 {
+		kinds = append(kinds, plural(c.StatFailures, "stat failure", "stat failures"))
+	}
+	return plural(c.Total(), "error", "errors") + ": " + strings.Join(kinds, ", ")
+}
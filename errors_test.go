@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "bad"},
+			{name: "locked", files: []*file{{name: "x"}}},
+			{name: "z"},
+		},
+	}
+	fs := &unreadableFs{NewFs().addFile(root.name, root), map[string]bool{"root/locked": true}}
+	var b, e bytes.Buffer
+	opts := &Options{Fs: fs, OutFile: &b}
+	tr := New(root.name)
+	tr.Visit(opts)
+
+	tr.Print(opts)
+	expected := `root
+├── a
+├── locked [permission denied]
+├── z
+└── bad [stat failed]
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	b.Reset()
+	opts.ErrOut = &e
+	tr.Print(opts)
+	expected = `root
+├── a
+├── locked
+└── z
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("errors to stderr:\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+	expected = "tree: root/locked: permission denied\ntree: root/bad: stat failed\n"
+	if actual := e.String(); actual != expected {
+		t.Errorf("errors to stderr:\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	var errs ErrorCounts
+	errs.Add(tr)
+	if s := errs.String(); s != "2 errors: 1 unreadable directory, 1 stat failure" {
+		t.Errorf("wrong error summary: %s", s)
+	}
+	entry := tr.Entry(opts)
+	for i, kind := range []string{"", ErrUnreadable, "", ErrStat} {
+		if entry.Contents[i].ErrorKind != kind {
+			t.Errorf("%s: expected error kind %q, got %q", entry.Contents[i].Name, kind, entry.Contents[i].ErrorKind)
+		}
+	}
+}
+
+func TestErrMessage(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&os.PathError{Op: "open", Path: "a: b", Err: os.ErrPermission}, "permission denied"},
+		{&os.LinkError{Op: "readlink", Old: "a", New: "b", Err: os.ErrNotExist}, "file does not exist"},
+		{errors.New("stat failed: no reason"), "stat failed: no reason"},
+	}
+	for _, test := range tests {
+		if actual := errMessage(test.err); actual != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.err, test.expected, actual)
+		}
+	}
+}
+
+// Mock filesystem with links, read through the Fs
+type linkFs struct {
+	*MockFs
+	links map[string]string
+}
+
+func (fs *linkFs) Stat(path string) (os.FileInfo, error) {
+	if fs.files[path] == nil {
+		return nil, &os.PathError{Op: "lstat", Path: path, Err: os.ErrNotExist}
+	}
+	return fs.MockFs.Stat(path)
+}
+
+func (fs *linkFs) Readlink(path string) (string, error) {
+	if target, ok := fs.links[path]; ok {
+		return target, nil
+	}
+	return "", &os.PathError{Op: "readlink", Path: path, Err: os.ErrInvalid}
+}
+
+func TestBrokenLinks(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "good", mode: os.ModeSymlink},
+			{name: "chain", mode: os.ModeSymlink},
+			{name: "broken", mode: os.ModeSymlink},
+		},
+	}
+	fs := &linkFs{NewFs().addFile(root.name, root), map[string]string{
+		"root/good":   "a",
+		"root/chain":  "good",
+		"root/broken": "missing",
+	}}
+	opts := &Options{Fs: fs}
+	tr := New(root.name)
+	tr.Visit(opts)
+	var errs ErrorCounts
+	errs.Add(tr)
+	if s := errs.String(); s != "1 error: 1 broken link" {
+		t.Errorf("wrong error summary: %s", s)
+	}
+	for _, node := range tr.nodes {
+		if broken := node.Name() == "broken"; node.brokenLink != broken {
+			t.Errorf("%s: expected broken %v", node.path, broken)
+		}
+	}
+}
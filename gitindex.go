@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+)
+
+// gitIndexEntry is an entry of the index of a repository, with the stat
+// data of the file when it was added.
+type gitIndexEntry struct {
+	path  string
+	mode  uint32
+	id    gitID
+	stage int
+	size  uint32
+	mtime time.Time
+	// assumeValid and skipWorktree entries are not compared with their
+	// file, intentToAdd ones were added by git add -N
+	assumeValid, skipWorktree, intentToAdd bool
+}
+
+// readGitIndex returns the entries of the index file at path, of version 2
+// to 4, and the time it was written. A missing index has no entries.
+func readGitIndex(path string) (entries []gitIndexEntry, written time.Time, err error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, written, nil
+	} else if err != nil {
+		return nil, written, err
+	}
+	if fi, err := os.Stat(path); err == nil {
+		written = fi.ModTime()
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, written, fmt.Errorf("%s: not a git index", path)
+	}
+	version := binary.BigEndian.Uint32(data[4:])
+	if version < 2 || version > 4 {
+		return nil, written, fmt.Errorf("%s: unsupported git index version %d", path, version)
+	}
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	bad := fmt.Errorf("%s: truncated git index", path)
+	rest := data[12:]
+	var prev string
+	for i := 0; i < n; i++ {
+		const fixed = 62
+		if len(rest) < fixed {
+			return nil, written, bad
+		}
+		flags := binary.BigEndian.Uint16(rest[60:])
+		e := gitIndexEntry{
+			mtime:       time.Unix(int64(binary.BigEndian.Uint32(rest[8:])), int64(binary.BigEndian.Uint32(rest[12:]))),
+			mode:        binary.BigEndian.Uint32(rest[24:]),
+			size:        binary.BigEndian.Uint32(rest[36:]),
+			stage:       int(flags>>12) & 3,
+			assumeValid: flags&0x8000 != 0,
+		}
+		copy(e.id[:], rest[40:])
+		size := fixed
+		if flags&0x4000 != 0 {
+			if len(rest) < fixed+2 {
+				return nil, written, bad
+			}
+			extended := binary.BigEndian.Uint16(rest[fixed:])
+			e.skipWorktree = extended&0x4000 != 0
+			e.intentToAdd = extended&0x2000 != 0
+			size += 2
+		}
+		if version == 4 {
+			// the path drops the end of the previous one and adds its own
+			r := bytes.NewReader(rest[size:])
+			strip, err := readGitVarint(r)
+			if err != nil || strip > uint64(len(prev)) {
+				return nil, written, bad
+			}
+			size = len(rest) - r.Len()
+			nul := bytes.IndexByte(rest[size:], 0)
+			if nul < 0 {
+				return nil, written, bad
+			}
+			e.path = prev[:len(prev)-int(strip)] + string(rest[size:size+nul])
+			size += nul + 1
+		} else {
+			nul := bytes.IndexByte(rest[size:], 0)
+			if nul < 0 {
+				return nil, written, bad
+			}
+			e.path = string(rest[size : size+nul])
+			// entries are padded with 1 to 8 NULs
+			size = (size + nul + 8) &^ 7
+		}
+		if size > len(rest) {
+			return nil, written, bad
+		}
+		rest = rest[size:]
+		prev = e.path
+		entries = append(entries, e)
+	}
+	return entries, written, nil
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gitID is the SHA-1 of a Git object.
+type gitID [20]byte
+
+func parseGitID(s string) (id gitID, ok bool) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != len(id) {
+		return id, false
+	}
+	copy(id[:], b)
+	return id, true
+}
+
+// Types of the objects, as numbered in packs.
+const (
+	gitCommit   = 1
+	gitTree     = 2
+	gitBlob     = 3
+	gitTag      = 4
+	gitOfsDelta = 6
+	gitRefDelta = 7
+)
+
+var gitTypes = map[string]int{"commit": gitCommit, "tree": gitTree, "blob": gitBlob, "tag": gitTag}
+
+// gitObjects reads the objects of a repository, loose or packed.
+type gitObjects struct {
+	dir   string
+	packs []*gitPack
+}
+
+// openGitObjects opens the object directory dir and the indexes of its
+// packs.
+func openGitObjects(dir string) (*gitObjects, error) {
+	o := &gitObjects{dir: dir}
+	idxs, _ := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+	for _, idx := range idxs {
+		p, err := openGitPack(idx)
+		if err != nil {
+			o.Close()
+			return nil, err
+		}
+		o.packs = append(o.packs, p)
+	}
+	return o, nil
+}
+
+func (o *gitObjects) Close() error {
+	for _, p := range o.packs {
+		p.file.Close()
+	}
+	return nil
+}
+
+// read returns the type and content of the object id.
+func (o *gitObjects) read(id gitID) (typ int, data []byte, err error) {
+	for _, p := range o.packs {
+		if offset, ok := p.find(id); ok {
+			return p.read(o, offset)
+		}
+	}
+	hexID := hex.EncodeToString(id[:])
+	file, err := os.Open(filepath.Join(o.dir, hexID[:2], hexID[2:]))
+	if os.IsNotExist(err) {
+		return 0, nil, fmt.Errorf("git object %s not found", hexID)
+	} else if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+	zr, err := zlib.NewReader(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+	header, err := r.ReadString(0)
+	if err != nil {
+		return 0, nil, fmt.Errorf("git object %s: %w", hexID, err)
+	}
+	var name string
+	var size int
+	if _, err := fmt.Sscanf(strings.TrimSuffix(header, "\x00"), "%s %d", &name, &size); err != nil || gitTypes[name] == 0 {
+		return 0, nil, fmt.Errorf("git object %s: bad header", hexID)
+	}
+	data = make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, fmt.Errorf("git object %s: %w", hexID, err)
+	}
+	return gitTypes[name], data, nil
+}
+
+// gitPack is a pack of objects, with its version 2 index.
+type gitPack struct {
+	file    *os.File
+	fanout  [256]uint32
+	ids     []byte
+	offsets []byte
+	large   []byte
+}
+
+func openGitPack(idx string) (*gitPack, error) {
+	data, err := os.ReadFile(idx)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8+256*4 || !bytes.Equal(data[:8], []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}) {
+		return nil, fmt.Errorf("%s: unsupported pack index", idx)
+	}
+	p := new(gitPack)
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(data[8+i*4:])
+	}
+	n := int(p.fanout[255])
+	ids := 8 + 256*4
+	offsets := ids + n*len(gitID{}) + n*4
+	if len(data) < offsets+n*4 {
+		return nil, fmt.Errorf("%s: truncated pack index", idx)
+	}
+	p.ids = data[ids : ids+n*len(gitID{})]
+	p.offsets = data[offsets : offsets+n*4]
+	p.large = data[offsets+n*4:]
+	if p.file, err = os.Open(strings.TrimSuffix(idx, ".idx") + ".pack"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// find returns the offset of the object id in the pack.
+func (p *gitPack) find(id gitID) (int64, bool) {
+	var lo int
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+	size := len(id)
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.ids[(lo+i)*size:(lo+i+1)*size], id[:]) >= 0
+	})
+	if i == hi || !bytes.Equal(p.ids[i*size:(i+1)*size], id[:]) {
+		return 0, false
+	}
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	// offsets over 2 GiB are in the table of large offsets
+	i = int(offset&0x7fffffff) * 8
+	if i+8 > len(p.large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[i:])), true
+}
+
+// read returns the type and content of the object at offset, applying
+// deltas to their base object.
+func (p *gitPack) read(o *gitObjects, offset int64) (typ int, data []byte, err error) {
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ = int(c>>4) & 7
+	size := uint64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= uint64(c&0x7f) << shift
+	}
+	var baseType int
+	var base []byte
+	switch typ {
+	case gitOfsDelta:
+		distance, err := readGitVarint(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = p.read(o, offset-int64(distance)); err != nil {
+			return 0, nil, err
+		}
+	case gitRefDelta:
+		var id gitID
+		if _, err := io.ReadFull(r, id[:]); err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = o.read(id); err != nil {
+			return 0, nil, err
+		}
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	data = make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return 0, nil, err
+	}
+	if base != nil {
+		data, err = applyGitDelta(base, data)
+		return baseType, data, err
+	}
+	return typ, data, nil
+}
+
+// readGitVarint reads the offset encoding of packs and of version 4
+// indexes, where each continuation adds one before shifting.
+func readGitVarint(r io.ByteReader) (uint64, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	n := uint64(c & 0x7f)
+	for c&0x80 != 0 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		n = (n+1)<<7 | uint64(c&0x7f)
+	}
+	return n, nil
+}
+
+var errGitDelta = errors.New("bad git delta")
+
+// applyGitDelta returns the object made of base and delta, a list of
+// copies of base and of inserted bytes.
+func applyGitDelta(base, delta []byte) ([]byte, error) {
+	size := func() uint64 {
+		var n uint64
+		for shift := 0; len(delta) > 0; shift += 7 {
+			c := delta[0]
+			delta = delta[1:]
+			n |= uint64(c&0x7f) << shift
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		return n
+	}
+	if size() != uint64(len(base)) {
+		return nil, errGitDelta
+	}
+	out := make([]byte, 0, size())
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		if op&0x80 == 0 {
+			// insert the next op bytes
+			if op == 0 || int(op) > len(delta) {
+				return nil, errGitDelta
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+			continue
+		}
+		// copy from base, the bits of op telling which bytes follow
+		var offset, n uint32
+		for i := uint(0); i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			if len(delta) == 0 {
+				return nil, errGitDelta
+			}
+			if i < 4 {
+				offset |= uint32(delta[0]) << (8 * i)
+			} else {
+				n |= uint32(delta[0]) << (8 * (i - 4))
+			}
+			delta = delta[1:]
+		}
+		if n == 0 {
+			n = 0x10000
+		}
+		if uint64(offset)+uint64(n) > uint64(len(base)) {
+			return nil, errGitDelta
+		}
+		out = append(out, base[offset:offset+n]...)
+	}
+	return out, nil
+}
+
+// gitTreeEntry is a file of a tree, which is a link to another repository
+// for submodules.
+type gitTreeEntry struct {
+	mode uint32
+	id   gitID
+}
+
+// readTree adds the files of the tree id to entries, by their path under
+// prefix.
+func (o *gitObjects) readTree(id gitID, prefix string, entries map[string]gitTreeEntry) error {
+	typ, data, err := o.read(id)
+	if err != nil {
+		return err
+	}
+	if typ != gitTree {
+		return fmt.Errorf("git object %x is not a tree", id)
+	}
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || len(data) < nul+1+len(id) {
+			return fmt.Errorf("git tree %x: bad entry", id)
+		}
+		mode, err := strconv.ParseUint(string(data[:sp]), 8, 32)
+		if err != nil {
+			return fmt.Errorf("git tree %x: bad mode", id)
+		}
+		path := prefix + string(data[sp+1:nul])
+		var eid gitID
+		copy(eid[:], data[nul+1:])
+		data = data[nul+1+len(eid):]
+		if mode == 040000 {
+			if err := o.readTree(eid, path+"/", entries); err != nil {
+				return err
+			}
+			continue
+		}
+		entries[path] = gitTreeEntry{mode: uint32(mode), id: eid}
+	}
+	return nil
+}
+
+// commitTree returns the tree of the commit id.
+func (o *gitObjects) commitTree(id gitID) (gitID, error) {
+	typ, data, err := o.read(id)
+	if err != nil {
+		return gitID{}, err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	tree, ok := parseGitID(strings.TrimPrefix(line, "tree "))
+	if typ != gitCommit || !strings.HasPrefix(line, "tree ") || !ok {
+		return gitID{}, fmt.Errorf("git object %x is not a commit", id)
+	}
+	return tree, nil
+}
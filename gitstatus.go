@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GitState is a set of states of an entry in Git.
+type GitState uint8
+
+// States of the entries in Git.
+const (
+	GitStaged GitState = 1 << iota
+	GitModified
+	GitUntracked
+	GitIgnored
+	GitConflicted
+	GitRenamed
+)
+
+var gitStates = []struct {
+	state  GitState
+	letter string
+	name   string
+}{
+	{GitStaged, "S", "staged"},
+	{GitModified, "M", "modified"},
+	{GitUntracked, "?", "untracked"},
+	{GitIgnored, "!", "ignored"},
+	{GitConflicted, "U", "conflicted"},
+	{GitRenamed, "R", "renamed"},
+}
+
+// String returns the names of the states, comma separated.
+func (s GitState) String() string {
+	var names []string
+	for _, gs := range gitStates {
+		if s&gs.state != 0 {
+			names = append(names, gs.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Letters returns the letters of the states, as shown in the text output:
+// S staged, M modified, ? untracked, ! ignored, U conflicted, R renamed.
+func (s GitState) Letters() string {
+	var letters string
+	for _, gs := range gitStates {
+		if s&gs.state != 0 {
+			letters += gs.letter
+		}
+	}
+	return letters
+}
+
+// GitStatus holds the states of the entries of Git worktrees, by absolute
+// path.
+type GitStatus struct {
+	// tops are the worktrees loaded
+	tops   []string
+	states map[string]GitState
+	// dirs are the untracked or ignored directories, whose contents share
+	// their state
+	dirs map[string]GitState
+	// changes are the states rolled up onto the directories holding the
+	// changed entries
+	changes map[string]GitState
+}
+
+// NewGitStatus returns an empty status.
+func NewGitStatus() *GitStatus {
+	return &GitStatus{
+		states:  make(map[string]GitState),
+		dirs:    make(map[string]GitState),
+		changes: make(map[string]GitState),
+	}
+}
+
+// Load adds the states of the entries of the worktree holding dir, read
+// from its index and HEAD commit. Nothing is added when dir is not in a
+// worktree.
+func (s *GitStatus) Load(dir string) error {
+	repo, err := findGitRepo(dir)
+	if err != nil || repo == nil {
+		return err
+	}
+	for _, top := range s.tops {
+		if top == repo.top {
+			return nil
+		}
+	}
+	states, err := repo.status()
+	if err != nil {
+		return err
+	}
+	s.tops = append(s.tops, repo.top)
+	for name, state := range states {
+		s.add(repo.top, name, state)
+	}
+	return nil
+}
+
+// add adds the state of the entry name, a slash-separated path in the
+// worktree top ending with a slash for directories.
+func (s *GitStatus) add(top, name string, state GitState) {
+	path := filepath.Join(top, filepath.FromSlash(name))
+	if strings.HasSuffix(name, "/") {
+		s.dirs[path] |= state
+	}
+	s.states[path] |= state
+	if state == GitIgnored {
+		return
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		s.changes[dir] |= state
+		if dir == top || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+}
+
+// gitRepo is a repository with a worktree.
+type gitRepo struct {
+	top string
+	// dir holds the index and HEAD, common the objects and refs, which
+	// differ for linked worktrees
+	dir, common string
+}
+
+// findGitRepo returns the repository whose worktree holds dir, nil if none.
+func findGitRepo(dir string) (*gitRepo, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	for top := dir; ; top = filepath.Dir(top) {
+		gitdir := filepath.Join(top, ".git")
+		fi, err := os.Stat(gitdir)
+		if err != nil {
+			if top == filepath.Dir(top) {
+				return nil, nil
+			}
+			continue
+		}
+		if !fi.IsDir() {
+			// a file pointing to the repository of a linked worktree or
+			// of a submodule
+			data, err := os.ReadFile(gitdir)
+			if err != nil {
+				return nil, err
+			}
+			line := strings.TrimSpace(string(data))
+			if !strings.HasPrefix(line, "gitdir: ") {
+				return nil, fmt.Errorf("%s: not a git file", gitdir)
+			}
+			gitdir = filepath.FromSlash(strings.TrimPrefix(line, "gitdir: "))
+			if !filepath.IsAbs(gitdir) {
+				gitdir = filepath.Join(top, gitdir)
+			}
+		}
+		repo := &gitRepo{top: top, dir: gitdir, common: gitdir}
+		if data, err := os.ReadFile(filepath.Join(gitdir, "commondir")); err == nil {
+			repo.common = filepath.FromSlash(strings.TrimSpace(string(data)))
+			if !filepath.IsAbs(repo.common) {
+				repo.common = filepath.Join(gitdir, repo.common)
+			}
+		}
+		return repo, nil
+	}
+}
+
+// head returns the files of the HEAD commit, none on an unborn branch.
+func (r *gitRepo) head(objs *gitObjects) (map[string]gitTreeEntry, error) {
+	entries := make(map[string]gitTreeEntry)
+	ref := "HEAD"
+	for i := 0; i < 10; i++ {
+		value, err := r.ref(ref)
+		if err != nil || value == "" {
+			return entries, err
+		}
+		if strings.HasPrefix(value, "ref: ") {
+			ref = strings.TrimSpace(strings.TrimPrefix(value, "ref: "))
+			continue
+		}
+		id, ok := parseGitID(value)
+		if !ok {
+			return nil, fmt.Errorf("git ref %s: bad object id", ref)
+		}
+		tree, err := objs.commitTree(id)
+		if err != nil {
+			return nil, err
+		}
+		return entries, objs.readTree(tree, "", entries)
+	}
+	return nil, fmt.Errorf("git ref %s: too many levels of symbolic refs", ref)
+}
+
+// ref returns the value of the ref name, loose or packed, empty when it
+// does not exist.
+func (r *gitRepo) ref(name string) (string, error) {
+	dir := r.common
+	if name == "HEAD" {
+		dir = r.dir
+	}
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err == nil {
+		return strings.TrimSpace(string(data)), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	packed, err := os.Open(filepath.Join(r.common, "packed-refs"))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer packed.Close()
+	scanner := bufio.NewScanner(packed)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[1] == name {
+			return fields[0], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+var gitSHA256 = regexp.MustCompile(`(?im)^\s*objectformat\s*=\s*sha256\s*$`)
+
+// status returns the states of the changed entries of the worktree, by
+// slash-separated path ending with a slash for directories. Files are
+// compared byte for byte, without the filters of .gitattributes.
+func (r *gitRepo) status() (map[string]GitState, error) {
+	if config, err := os.ReadFile(filepath.Join(r.common, "config")); err == nil && gitSHA256.Match(config) {
+		return nil, fmt.Errorf("%s: SHA-256 repositories are not supported", r.top)
+	}
+	objs, err := openGitObjects(filepath.Join(r.common, "objects"))
+	if err != nil {
+		return nil, err
+	}
+	defer objs.Close()
+	head, err := r.head(objs)
+	if err != nil {
+		return nil, err
+	}
+	index, written, err := readGitIndex(filepath.Join(r.dir, "index"))
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]GitState)
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	staged := make(map[string]gitIndexEntry)
+	for _, e := range index {
+		files[e.path] = true
+		for dir := path.Dir(e.path); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+		if e.stage != 0 {
+			states[e.path] = GitConflicted
+			continue
+		}
+		staged[e.path] = e
+	}
+
+	// the index against HEAD, files deleted and added with the same
+	// content being renamed
+	var added []string
+	for name, e := range staged {
+		if e.intentToAdd {
+			states[name] |= GitModified
+			continue
+		}
+		if h, ok := head[name]; !ok {
+			added = append(added, name)
+			states[name] |= GitStaged
+		} else if h.id != e.id || h.mode != e.mode {
+			states[name] |= GitStaged
+		}
+	}
+	deleted := make(map[gitID][]string)
+	for name, h := range head {
+		if !files[name] {
+			states[name] |= GitStaged
+			deleted[h.id] = append(deleted[h.id], name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		id := staged[name].id
+		if olds := deleted[id]; len(olds) > 0 {
+			sort.Strings(olds)
+			states[name] |= GitRenamed
+			delete(states, olds[0])
+			deleted[id] = olds[1:]
+		}
+	}
+
+	// the worktree against the index
+	for name, e := range staged {
+		if e.assumeValid || e.skipWorktree || e.intentToAdd || e.mode == 0160000 {
+			continue
+		}
+		if r.modified(e, written) {
+			states[name] |= GitModified
+		}
+	}
+
+	// the files out of the index
+	var rules []gitIgnoreRule
+	if file := gitExcludesFile(); file != "" {
+		rules = readGitIgnore(file, "")
+	}
+	rules = append(rules, readGitIgnore(filepath.Join(r.common, "info", "exclude"), "")...)
+	r.walk("", rules, false, files, dirs, states)
+	return states, nil
+}
+
+// modified tells whether the file of the entry e differs from it, in an
+// index written at written.
+func (r *gitRepo) modified(e gitIndexEntry, written time.Time) bool {
+	file := filepath.Join(r.top, filepath.FromSlash(e.path))
+	fi, err := os.Lstat(file)
+	if err != nil {
+		return true
+	}
+	isLink := e.mode&0170000 == 0120000
+	if isLink != (fi.Mode()&os.ModeSymlink != 0) || !isLink && !fi.Mode().IsRegular() {
+		return true
+	}
+	if !isLink && runtime.GOOS != "windows" && (e.mode&0111 != 0) != (fi.Mode()&0111 != 0) {
+		return true
+	}
+	if uint32(fi.Size()) != e.size {
+		return true
+	}
+	mtime := fi.ModTime()
+	if e.mtime.Nanosecond() == 0 {
+		mtime = mtime.Truncate(time.Second)
+	}
+	if mtime.Equal(e.mtime) && e.mtime.Before(written) {
+		return false
+	}
+	// the file was touched, or changed as the index was written
+	var data []byte
+	if isLink {
+		var target string
+		target, err = os.Readlink(file)
+		data = []byte(filepath.ToSlash(target))
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	return err != nil || gitBlobID(data) != e.id
+}
+
+// gitBlobID returns the id of the blob of content data.
+func gitBlobID(data []byte) (id gitID) {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+// walk adds the untracked and ignored entries of the directory rel, files
+// and dirs being those of the index. Untracked directories have their
+// entries listed, ignored ones are not read.
+func (r *gitRepo) walk(rel string, rules []gitIgnoreRule, ignored bool, files, dirs map[string]bool, states map[string]GitState) {
+	dir := filepath.Join(r.top, filepath.FromSlash(rel))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	rules = append(rules[:len(rules):len(rules)], readGitIgnore(filepath.Join(dir, ".gitignore"), rel)...)
+	for _, entry := range entries {
+		name := path.Join(rel, entry.Name())
+		if entry.Name() == ".git" || files[name] {
+			continue
+		}
+		isDir := entry.IsDir()
+		ign := ignored || gitIgnored(rules, name, isDir)
+		switch {
+		case isDir && dirs[name]:
+			r.walk(name, rules, ign, files, dirs, states)
+		case isDir && ign:
+			states[name+"/"] = GitIgnored
+		case isDir:
+			if _, err := os.Lstat(filepath.Join(dir, entry.Name(), ".git")); err == nil {
+				// another repository
+				states[name+"/"] = GitUntracked
+				continue
+			}
+			r.walk(name, rules, false, files, dirs, states)
+		case ign:
+			states[name] = GitIgnored
+		default:
+			states[name] = GitUntracked
+		}
+	}
+}
+
+// gitExcludesFile returns the default path of the global ignore file.
+func gitExcludesFile() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "git", "ignore")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "git", "ignore")
+	}
+	return ""
+}
+
+// State returns the state of the entry at path, with those of its contents
+// for directories.
+func (s *GitStatus) State(path string) GitState {
+	path = realPath(path)
+	state := s.states[path] | s.changes[path]
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if dstate, ok := s.dirs[dir]; ok {
+			return state | dstate
+		}
+	}
+	return state
+}
+
+// InWorktree tells whether path is in one of the worktrees loaded.
+func (s *GitStatus) InWorktree(path string) bool {
+	path = realPath(path)
+	for _, top := range s.tops {
+		if path == top || strings.HasPrefix(path, top+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed tells whether the entry at path, or its contents, has changes.
+func (s *GitStatus) Changed(path string) bool {
+	return s.State(path)&^GitIgnored != 0
+}
+
+// realPath returns the absolute path, with the links of its directory
+// resolved, as given by git and the mount table.
+func realPath(path string) string {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	return path
+}
+
+func init() {
+	// the column is left empty out of the worktrees
+	value := func(opts *Options, node *Node) (state GitState, stated, ok bool) {
+		if opts.Git == nil || node.FileInfo == nil {
+			return 0, false, false
+		}
+		if !opts.Git.InWorktree(node.path) {
+			return 0, true, false
+		}
+		return opts.Git.State(node.path), true, true
+	}
+	RegisterColumn(&funcColumn{
+		name: "git",
+		value: func(opts *Options, node *Node) (string, bool) {
+			state, _, ok := value(opts, node)
+			return state.String(), ok && state != 0
+		},
+		text: func(opts *Options, node *Node) (string, bool) {
+			state, stated, ok := value(opts, node)
+			letters := state.Letters()
+			if letters == "" && ok {
+				letters = "-"
+			}
+			return fmt.Sprintf("%-2s", letters), stated
+		},
+	})
+}
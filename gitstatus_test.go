@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+func TestGitStatus(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "b"},
+			{name: "clean"},
+			{name: "ign", files: []*file{{name: "d"}}},
+			{name: "new"},
+			{name: "other", files: []*file{{name: "e"}}},
+			{name: "sub", files: []*file{{name: "c"}}},
+			{name: "x"},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	status := NewGitStatus()
+	top := filepath.Dir(realPath("root"))
+	status.tops = []string{top}
+	for name, state := range map[string]GitState{
+		"root/a":     GitModified,
+		"root/b":     GitStaged | GitModified,
+		"root/sub/c": GitUntracked,
+		"root/ign/":  GitIgnored,
+		"root/new":   GitStaged | GitRenamed,
+		"root/x":     GitConflicted,
+	} {
+		status.add(top, name, state)
+	}
+
+	var states = []struct {
+		path     string
+		expected GitState
+	}{
+		{"root/a", GitModified},
+		{"root/b", GitStaged | GitModified},
+		{"root/clean", 0},
+		{"root/ign", GitIgnored},
+		{"root/ign/d", GitIgnored},
+		{"root/new", GitStaged | GitRenamed},
+		{"root/sub", GitUntracked},
+		{"root/sub/c", GitUntracked},
+		{"root/x", GitConflicted},
+		{"root", GitStaged | GitModified | GitUntracked | GitConflicted | GitRenamed},
+	}
+	for _, test := range states {
+		if actual := status.State(test.path); actual != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.path, actual, test.expected)
+		}
+	}
+
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b, Git: status, GitChanged: true}
+	tr.Visit(opts)
+	tr.Print(opts)
+	expected := `[SM?UR]  root
+├── [M ]  a
+├── [SM]  b
+├── [SR]  new
+├── [? ]  sub
+│   └── [? ]  c
+└── [U ]  x
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+// gitRun runs git in dir, out of the configuration of the user.
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-C", dir, "-c", "user.name=tree", "-c", "user.email=tree@example.com",
+		"-c", "init.defaultBranch=main", "-c", "core.autocrlf=false"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestGitRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := realPath(t.TempDir())
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitRun(t, dir, "init", "-q")
+	for _, name := range []string{"a", "b", "clean", "gone", "old", "s/d/e"} {
+		write(name, strings.Repeat(name+"\n", 100))
+	}
+	write(".gitignore", "*.log\n/build/\n!keep.log\n")
+	if err := os.Symlink("a", filepath.Join(dir, "link")); err != nil {
+		t.Skip("symbolic links not supported:", err)
+	}
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "-m", "first")
+	write("s/d/e", strings.Repeat("e\n", 101))
+	gitRun(t, dir, "commit", "-q", "-a", "-m", "second")
+
+	write("a", "changed\n")
+	write("b", "staged\n")
+	gitRun(t, dir, "add", "b")
+	write("b", "staged and changed\n")
+	gitRun(t, dir, "mv", "old", "new")
+	gitRun(t, dir, "rm", "-q", "gone")
+	write("clean", strings.Repeat("clean\n", 100))
+	write("u/x", "x\n")
+	write("x.log", "x\n")
+	write("keep.log", "x\n")
+	write("build/o", "x\n")
+	write("nested/.git", "gitdir: elsewhere\n")
+
+	var states = []struct {
+		path     string
+		expected GitState
+	}{
+		{"a", GitModified},
+		{"b", GitStaged | GitModified},
+		{"clean", 0},
+		{"link", 0},
+		{"gone", GitStaged},
+		{"new", GitStaged | GitRenamed},
+		{"old", 0},
+		{"s", 0},
+		{"u", GitUntracked},
+		{"u/x", GitUntracked},
+		{"x.log", GitIgnored},
+		{"keep.log", GitUntracked},
+		{"build/o", GitIgnored},
+		{"nested", GitUntracked},
+		{".", GitStaged | GitModified | GitUntracked | GitRenamed},
+	}
+	check := func(name string) {
+		status := NewGitStatus()
+		if err := status.Load(filepath.Join(dir, "s")); err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range states {
+			if actual := status.State(filepath.Join(dir, test.path)); actual != test.expected {
+				t.Errorf("%s: %s: got %q, expected %q", name, test.path, actual, test.expected)
+			}
+		}
+	}
+	check("loose")
+	gitRun(t, dir, "gc", "-q", "--aggressive")
+	gitRun(t, dir, "update-index", "--index-version", "4")
+	check("packed")
+
+	// out of a worktree
+	other := t.TempDir()
+	os.WriteFile(filepath.Join(other, "f"), nil, 0644)
+	status := NewGitStatus()
+	if err := status.Load(other); err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	opts := &Options{Fs: new(ostree.FS), OutFile: b, Git: status}
+	tr := New(other)
+	tr.Visit(opts)
+	tr.Print(opts)
+	if expected := "[  ]  " + other + "\n└── [  ]  f\n"; b.String() != expected {
+		t.Errorf("out of a worktree got:\n%s\nexpected:\n%s", b.String(), expected)
+	}
+}
+
+func TestGitIgnore(t *testing.T) {
+	rules := []gitIgnoreRule{}
+	for _, line := range []string{"*.o", "/top", "doc/*.txt", "**/deep/x", "out/", "a/**", "!keep.o", "# comment", `\#hash`, "[ab]?.c"} {
+		if rule, ok := parseGitIgnore(line, ""); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if rule, ok := parseGitIgnore("*.tmp", "sub"); ok {
+		rules = append(rules, rule)
+	}
+	tests := []struct {
+		path     string
+		dir      bool
+		expected bool
+	}{
+		{"x.o", false, true},
+		{"d/x.o", false, true},
+		{"keep.o", false, false},
+		{"top", false, true},
+		{"d/top", false, false},
+		{"doc/a.txt", false, true},
+		{"doc/d/a.txt", false, false},
+		{"deep/x", false, true},
+		{"a/b/deep/x", false, true},
+		{"out", true, true},
+		{"out", false, false},
+		{"a/b/c", false, true},
+		{"a", true, false},
+		{"#hash", false, true},
+		{"comment", false, false},
+		{"bx.c", false, true},
+		{"cx.c", false, false},
+		{"sub/a.tmp", false, true},
+		{"a.tmp", false, false},
+	}
+	for _, test := range tests {
+		if actual := gitIgnored(rules, test.path, test.dir); actual != test.expected {
+			t.Errorf("%s: got %v, expected %v", test.path, actual, test.expected)
+		}
+	}
+}
@@ -2,4 +2,16 @@ module github.com/fiatjaf/tree
 
 go 1.20
 
-require github.com/urfave/cli/v3 v3.0.0-beta1
+require (
+	github.com/cespare/xxhash/v2 v2.2.0
+	github.com/urfave/cli/v3 v3.0.0-beta1
+	golang.org/x/crypto v0.16.0
+	golang.org/x/term v0.15.0
+	golang.org/x/text v0.14.0
+	lukechampine.com/blake3 v1.2.1
+)
+
+require (
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+)
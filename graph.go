@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// GraphFormats are the diagram syntaxes of the graph output: Graphviz
+// digraph, Mermaid flowchart and Mermaid mindmap.
+var GraphFormats = []string{"dot", "mermaid", "mindmap"}
+
+// GraphOptions selects how the trees are drawn by PrintGraph.
+type GraphOptions struct {
+	// Format is one of GraphFormats
+	Format string
+	// Cluster draws the contents of each directory in a box, except in
+	// mindmaps, whose layout already groups them
+	Cluster bool
+	// Count adds the number of entries to the labels of directories
+	Count bool
+}
+
+// PrintGraph prints the trees as a diagram. Labels hold the size of
+// entries when one of the size options is set.
+func PrintGraph(opts *Options, gopts GraphOptions, trees Nodes) error {
+	g := &graphWriter{Writer: bufio.NewWriter(opts.OutFile), opts: opts, gopts: gopts}
+	switch gopts.Format {
+	case "dot":
+		g.WriteString("digraph tree {\n")
+		g.WriteString("  node [shape=box];\n")
+		for _, node := range trees {
+			g.dot(node, "  ")
+		}
+		g.WriteString("}\n")
+	case "mermaid":
+		g.WriteString("graph TD\n")
+		for _, node := range trees {
+			g.mermaid(node, "", "  ")
+		}
+	case "mindmap":
+		g.WriteString("mindmap\n")
+		indent := "  "
+		if len(trees) > 1 {
+			// mindmaps have a single root
+			fmt.Fprintf(g, "%s%s((\" \"))\n", indent, g.id())
+			indent += "  "
+		}
+		for _, node := range trees {
+			g.mindmap(node, indent)
+		}
+	default:
+		return fmt.Errorf("graph format '%s' not valid, should be one of: %s",
+			gopts.Format, strings.Join(GraphFormats, ","))
+	}
+	return g.Flush()
+}
+
+type graphWriter struct {
+	*bufio.Writer
+	opts  *Options
+	gopts GraphOptions
+	// n numbers the nodes of the graph
+	n int
+}
+
+func (g *graphWriter) id() string {
+	id := fmt.Sprintf("n%d", g.n)
+	g.n++
+	return id
+}
+
+// label returns the lines of the label of the node: its name, followed by
+// its metadata, if any.
+func (g *graphWriter) label(node *Node) []string {
+	name := node.path
+	if node.depth != 0 && !g.opts.FullPath {
+		name = baseName(node)
+	}
+	if node.err != nil {
+		return []string{name, "[" + errMessage(node.err) + "]"}
+	}
+	var meta []string
+	if g.opts.ByteSize || g.opts.UnitSize {
+		size := node.Size()
+		if node.IsDir() {
+			size, _ = dirRecursiveSize(g.opts, node)
+		}
+		if g.opts.UnitSize {
+			meta = append(meta, formatBytes(size))
+		} else {
+			meta = append(meta, fmt.Sprintf("%d bytes", size))
+		}
+	}
+	if g.gopts.Count && node.IsDir() {
+		meta = append(meta, fmt.Sprintf("%d entries", len(node.nodes)))
+	}
+	if len(meta) == 0 {
+		return []string{name}
+	}
+	return []string{name, strings.Join(meta, ", ")}
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (g *graphWriter) dot(node *Node, indent string) string {
+	id := g.id()
+	lines := g.label(node)
+	for i, line := range lines {
+		lines[i] = dotEscaper.Replace(line)
+	}
+	attrs := fmt.Sprintf("label=\"%s\"", strings.Join(lines, `\n`))
+	switch {
+	case node.err != nil:
+		attrs += ", color=red"
+	case node.IsDir():
+		attrs += ", shape=folder"
+	default:
+		attrs += ", shape=note"
+	}
+	fmt.Fprintf(g, "%s%s [%s];\n", indent, id, attrs)
+	if len(node.nodes) == 0 {
+		return id
+	}
+	cindent := indent
+	if g.gopts.Cluster {
+		fmt.Fprintf(g, "%ssubgraph cluster_%s {\n", indent, id)
+		fmt.Fprintf(g, "%s  label=\"%s\";\n", indent, lines[0])
+		cindent += "  "
+	}
+	for _, nnode := range node.nodes {
+		nid := g.dot(nnode, cindent)
+		fmt.Fprintf(g, "%s%s -> %s;\n", cindent, id, nid)
+	}
+	if g.gopts.Cluster {
+		fmt.Fprintf(g, "%s}\n", indent)
+	}
+	return id
+}
+
+// mermaidEscaper escapes the characters ending a quoted label, or taken as
+// HTML, with Mermaid entity codes.
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "#", "#35;")
+
+func (g *graphWriter) mermaidLabel(node *Node) string {
+	lines := g.label(node)
+	for i, line := range lines {
+		lines[i] = mermaidEscaper.Replace(line)
+	}
+	return `"` + strings.Join(lines, "<br>") + `"`
+}
+
+func (g *graphWriter) mermaid(node *Node, parent, indent string) {
+	id := g.id()
+	shape := "[%s]"
+	if node.err == nil && node.IsDir() {
+		shape = "(%s)"
+	}
+	if parent == "" {
+		fmt.Fprintf(g, "%s%s"+shape+"\n", indent, id, g.mermaidLabel(node))
+	} else {
+		fmt.Fprintf(g, "%s%s --> %s"+shape+"\n", indent, parent, id, g.mermaidLabel(node))
+	}
+	if len(node.nodes) == 0 {
+		return
+	}
+	cindent := indent
+	if g.gopts.Cluster {
+		fmt.Fprintf(g, "%ssubgraph %s_contents [%s]\n", indent, id, g.mermaidLabel(node))
+		cindent += "  "
+	}
+	for _, nnode := range node.nodes {
+		g.mermaid(nnode, id, cindent)
+	}
+	if g.gopts.Cluster {
+		fmt.Fprintf(g, "%send\n", indent)
+	}
+}
+
+func (g *graphWriter) mindmap(node *Node, indent string) {
+	shape := "[%s]"
+	if node.depth == 0 {
+		shape = "((%s))"
+	} else if node.err == nil && node.IsDir() {
+		shape = "(%s)"
+	}
+	fmt.Fprintf(g, "%s%s"+shape+"\n", indent, g.id(), g.mermaidLabel(node))
+	for _, nnode := range node.nodes {
+		g.mindmap(nnode, indent+"  ")
+	}
+}
+
+// graphRenderer renders the trees as a diagram configured by opts.Graph,
+// once they are whole.
+type graphRenderer struct {
+	treeCollector
+	opts *Options
+}
+
+// Report leaves out the report, which has no place in a diagram.
+func (r *graphRenderer) Report(report *Report) error { return nil }
+
+func (r *graphRenderer) Flush() error {
+	gopts := r.opts.Graph
+	if gopts.Format == "" {
+		gopts.Format = GraphFormats[0]
+	}
+	return PrintGraph(r.opts, gopts, r.trees)
+}
+
+func init() {
+	RegisterRenderer("graph", func(opts *Options) Renderer { return &graphRenderer{opts: opts} })
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+func TestGraph(t *testing.T) {
+	tests := []struct {
+		gopts    GraphOptions
+		expected string
+	}{
+		{GraphOptions{Format: "dot", Count: true}, `digraph tree {
+  node [shape=box];
+  n0 [label="ostree/testdata\n2 entries", shape=folder];
+  n1 [label="a\n1 entries", shape=folder];
+  n2 [label="b\n1 entries", shape=folder];
+  n3 [label="b.txt", shape=note];
+  n2 -> n3;
+  n1 -> n2;
+  n0 -> n1;
+  n4 [label="c\n1 entries", shape=folder];
+  n5 [label="c.txt", shape=note];
+  n4 -> n5;
+  n0 -> n4;
+}
+`},
+		{GraphOptions{Format: "dot", Cluster: true}, `digraph tree {
+  node [shape=box];
+  n0 [label="ostree/testdata", shape=folder];
+  subgraph cluster_n0 {
+    label="ostree/testdata";
+    n1 [label="a", shape=folder];
+    subgraph cluster_n1 {
+      label="a";
+      n2 [label="b", shape=folder];
+      subgraph cluster_n2 {
+        label="b";
+        n3 [label="b.txt", shape=note];
+        n2 -> n3;
+      }
+      n1 -> n2;
+    }
+    n0 -> n1;
+    n4 [label="c", shape=folder];
+    subgraph cluster_n4 {
+      label="c";
+      n5 [label="c.txt", shape=note];
+      n4 -> n5;
+    }
+    n0 -> n4;
+  }
+}
+`},
+		{GraphOptions{Format: "mermaid", Count: true}, `graph TD
+  n0("ostree/testdata<br>2 entries")
+  n0 --> n1("a<br>1 entries")
+  n1 --> n2("b<br>1 entries")
+  n2 --> n3["b.txt"]
+  n0 --> n4("c<br>1 entries")
+  n4 --> n5["c.txt"]
+`},
+		{GraphOptions{Format: "mermaid", Cluster: true}, `graph TD
+  n0("ostree/testdata")
+  subgraph n0_contents ["ostree/testdata"]
+    n0 --> n1("a")
+    subgraph n1_contents ["a"]
+      n1 --> n2("b")
+      subgraph n2_contents ["b"]
+        n2 --> n3["b.txt"]
+      end
+    end
+    n0 --> n4("c")
+    subgraph n4_contents ["c"]
+      n4 --> n5["c.txt"]
+    end
+  end
+`},
+		{GraphOptions{Format: "mindmap"}, `mindmap
+  n0(("ostree/testdata"))
+    n1("a")
+      n2("b")
+        n3["b.txt"]
+    n4("c")
+      n5["c.txt"]
+`},
+	}
+	for _, test := range tests {
+		b := new(bytes.Buffer)
+		tr := New("ostree/testdata")
+		opts := &Options{Fs: new(ostree.FS), OutFile: b}
+		tr.Visit(opts)
+		if err := PrintGraph(opts, test.gopts, Nodes{tr}); err != nil {
+			t.Fatal(err)
+		}
+		if actual := b.String(); actual != test.expected {
+			t.Errorf("%+v:\ngot:\n%s\nexpected:\n%s", test.gopts, actual, test.expected)
+		}
+	}
+}
+
+func TestGraphEscape(t *testing.T) {
+	root := &file{
+		name:  "root",
+		files: []*file{{name: `a "b" <c> #1 \d`, size: 10}},
+	}
+	fs := NewFs().addFile(root.name, root)
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b, ByteSize: true}
+	tr.Visit(opts)
+
+	PrintGraph(opts, GraphOptions{Format: "dot"}, Nodes{tr})
+	expected := `digraph tree {
+  node [shape=box];
+  n0 [label="root\n10 bytes", shape=folder];
+  n1 [label="a \"b\" <c> #1 \\d\n10 bytes", shape=note];
+  n0 -> n1;
+}
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("dot:\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+	b.Reset()
+	PrintGraph(opts, GraphOptions{Format: "mermaid"}, Nodes{tr})
+	expected = `graph TD
+  n0("root<br>10 bytes")
+  n0 --> n1["a #quot;b#quot; #lt;c#gt; #35;1 \d<br>10 bytes"]
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("mermaid:\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
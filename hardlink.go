@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// HardLinks holds the files of trees sharing their inode with others.
+type HardLinks struct {
+	// links are the numbers of links of the files, by path
+	links map[string]uint64
+	// targets are the first paths of the inodes, by path of their other
+	// links
+	targets map[string]string
+}
+
+// FindHardLinks returns the hard links of the trees, the first of each
+// inode in the order of the trees being its target.
+func FindHardLinks(trees Nodes) *HardLinks {
+	h := &HardLinks{links: make(map[string]uint64), targets: make(map[string]string)}
+	type key struct{ device, inode uint64 }
+	first := make(map[key]string)
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		if node.FileInfo != nil && !node.IsDir() {
+			n, _ := linkCount(node)
+			ok, inode, device, _, _ := getStat(node)
+			if ok && n > 1 {
+				h.links[node.path] = n
+				k := key{device, inode}
+				if target, seen := first[k]; seen {
+					h.targets[node.path] = target
+				} else {
+					first[k] = node.path
+				}
+			}
+		}
+		for _, nnode := range node.nodes {
+			walk(nnode)
+		}
+	}
+	for _, node := range trees {
+		walk(node)
+	}
+	return h
+}
+
+// Links returns the number of links of the file at path, when it has more
+// than one.
+func (h *HardLinks) Links(path string) (uint64, bool) {
+	n, ok := h.links[path]
+	return n, ok
+}
+
+// Target returns the first path of the inode of the file at path, when it
+// is not path itself.
+func (h *HardLinks) Target(path string) (string, bool) {
+	target, ok := h.targets[path]
+	return target, ok
+}
+
+// Count returns the number of files linking to an inode listed before.
+func (h *HardLinks) Count() int {
+	return len(h.targets)
+}
+
+// isCountedOnce tells whether the node is a link to an inode listed
+// before, left out of sizes and counts.
+func (opts *Options) isCountedOnce(node *Node) bool {
+	if opts.HardLinks == nil || opts.CountLinks {
+		return false
+	}
+	_, ok := opts.HardLinks.Target(node.path)
+	return ok
+}
+
+func init() {
+	links := func(opts *Options, node *Node) (uint64, bool) {
+		if opts.HardLinks == nil {
+			return 0, false
+		}
+		return opts.HardLinks.Links(node.path)
+	}
+	RegisterColumn(&funcColumn{
+		name: "links",
+		value: func(opts *Options, node *Node) (string, bool) {
+			n, ok := links(opts, node)
+			return strconv.FormatUint(n, 10), ok
+		},
+		text: func(opts *Options, node *Node) (string, bool) {
+			n, ok := links(opts, node)
+			return fmt.Sprintf("%d links", n), ok
+		},
+	})
+	RegisterColumn(NewColumn("hardlink", func(opts *Options, node *Node) (string, bool) {
+		if opts.HardLinks == nil {
+			return "", false
+		}
+		return opts.HardLinks.Target(node.path)
+	}))
+}
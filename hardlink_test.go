@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+func TestHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "s"), 0755)
+	if err := os.WriteFile(filepath.Join(dir, "a"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "c"), []byte("x\n"), 0644)
+	for _, name := range []string{"s/b", "z"} {
+		if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, name)); err != nil {
+			t.Skip("hard links not supported:", err)
+		}
+	}
+	b := new(bytes.Buffer)
+	opts := &Options{Fs: new(ostree.FS), OutFile: b, ByteSize: true}
+	tr := New(dir)
+	tr.Visit(opts)
+	opts.HardLinks = FindHardLinks(Nodes{tr})
+	if n := opts.HardLinks.Count(); n != 2 {
+		t.Errorf("expected 2 hard links, got %d", n)
+	}
+	tr.Print(opts)
+	a := filepath.Join(dir, "a")
+	expected := `[          8]  ` + dir + `
+├── [          6 3 links]  a
+├── [          2]  c
+├── [          0]  s
+│   └── [          6 3 links]  b [hardlink to ` + a + `]
+└── [          6 3 links]  z [hardlink to ` + a + `]
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	// read back by --to-fs
+	b.Reset()
+	topts := &Options{Fs: opts.Fs, OutFile: b, HardLinks: opts.HardLinks}
+	tr.Print(topts)
+	report := &Report{Directories: 1, Files: 2, HardLinks: 2}
+	fmt.Fprintf(b, "\n%s\n", report.footer(topts))
+	trees, err := ParseSkeleton(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	dst := t.TempDir()
+	if err := CreateSkeleton(dst, trees, true, out); err != nil {
+		t.Fatal(err)
+	}
+	expected = strings.Join([]string{"a", "c", "s/", "s/b", "z"}, "\n"+dst+"/")
+	if actual := out.String(); actual != dst+"/"+expected+"\n" {
+		t.Errorf("--to-fs got:\n%s", actual)
+	}
+	if footer := (&Report{Directories: 1, Files: 2, HardLinks: 1}).footer(topts); footer != "1 directories, 2 files, 1 hard link" {
+		t.Errorf("wrong footer: %s", footer)
+	}
+
+	opts.CountLinks = true
+	if size, _ := dirRecursiveSize(opts, tr); size != 20 {
+		t.Errorf("expected a size of 20 counting each link, got %d", size)
+	}
+}
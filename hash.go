@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+	"lukechampine.com/blake3"
+)
+
+// HashAlgorithms are the algorithms of the content hashes.
+var HashAlgorithms = []string{"sha256", "blake2b", "blake3", "xxhash", "crc32"}
+
+func newHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New, nil
+	case "blake2b":
+		return func() hash.Hash {
+			h, _ := blake2b.New256(nil)
+			return h
+		}, nil
+	case "blake3":
+		return func() hash.Hash { return blake3.New(32, nil) }, nil
+	case "xxhash":
+		return func() hash.Hash { return xxhash.New() }, nil
+	case "crc32":
+		return func() hash.Hash { return crc32.NewIEEE() }, nil
+	}
+	return nil, fmt.Errorf("hash algorithm '%s' not valid, should be one of: %s",
+		algorithm, strings.Join(HashAlgorithms, ","))
+}
+
+// partialSize is the number of bytes hashed first when looking for
+// duplicates.
+const partialSize = 4096
+
+// Hashes holds the content hashes of the regular files of trees, and the
+// groups of files sharing their content.
+type Hashes struct {
+	// Workers is the number of files read at once, 1 when not set.
+	Workers int
+	newHash func() hash.Hash
+	// sums are the hex hashes, by path
+	sums map[string]string
+	// dupes are the group ids, from 1, by path
+	dupes map[string]int
+}
+
+// NewHashes returns the hashes of files computed with algorithm, one of
+// HashAlgorithms.
+func NewHashes(algorithm string, workers int) (*Hashes, error) {
+	newHash, err := newHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &Hashes{Workers: workers, newHash: newHash}, nil
+}
+
+// Hash computes the hashes of the regular files of the trees. Files that
+// could not be read have none.
+func (h *Hashes) Hash(ctx context.Context, opts *Options, trees Nodes) {
+	var paths []string
+	for _, node := range regularFiles(trees) {
+		paths = append(paths, node.path)
+	}
+	h.sums = h.hashFiles(ctx, opts, paths, 0)
+}
+
+// FindDupes groups the regular files of the trees sharing their content,
+// empty ones aside: files of the same size are compared by the hash of
+// their first bytes, and those still alike by the hash of their content,
+// reusing the hashes of Hash when computed. Groups are numbered in the
+// order of the trees.
+func (h *Hashes) FindDupes(ctx context.Context, opts *Options, trees Nodes) {
+	files := regularFiles(trees)
+	sizes := make(map[string]int64)
+	bySize := make(map[int64][]string)
+	for _, node := range files {
+		sizes[node.path] = node.Size()
+		if node.Size() > 0 {
+			bySize[node.Size()] = append(bySize[node.Size()], node.path)
+		}
+	}
+	key := func(path, sum string) string {
+		return strconv.FormatInt(sizes[path], 10) + ":" + sum
+	}
+	// files of the same size
+	var candidates []string
+	for _, paths := range bySize {
+		if len(paths) > 1 {
+			candidates = append(candidates, paths...)
+		}
+	}
+	// then of the same first bytes
+	partial := h.hashFiles(ctx, opts, candidates, partialSize)
+	byPartial := make(map[string][]string)
+	for _, path := range candidates {
+		if sum, ok := partial[path]; ok {
+			byPartial[key(path, sum)] = append(byPartial[key(path, sum)], path)
+		}
+	}
+	// then of the same content
+	sums := make(map[string]string)
+	var full []string
+	for _, paths := range byPartial {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, path := range paths {
+			if sizes[path] <= partialSize {
+				sums[path] = partial[path]
+			} else if sum, ok := h.sums[path]; ok {
+				sums[path] = sum
+			} else {
+				full = append(full, path)
+			}
+		}
+	}
+	for path, sum := range h.hashFiles(ctx, opts, full, 0) {
+		sums[path] = sum
+	}
+	count := make(map[string]int)
+	for path, sum := range sums {
+		count[key(path, sum)]++
+	}
+	h.dupes = make(map[string]int)
+	ids := make(map[string]int)
+	for _, node := range files {
+		sum, ok := sums[node.path]
+		if !ok || count[key(node.path, sum)] < 2 {
+			continue
+		}
+		k := key(node.path, sum)
+		if ids[k] == 0 {
+			ids[k] = len(ids) + 1
+		}
+		h.dupes[node.path] = ids[k]
+	}
+}
+
+// Sum returns the hex hash of the file at path.
+func (h *Hashes) Sum(path string) (string, bool) {
+	sum, ok := h.sums[path]
+	return sum, ok
+}
+
+// Dupe returns the group of the file at path, when it has duplicates.
+func (h *Hashes) Dupe(path string) (int, bool) {
+	id, ok := h.dupes[path]
+	return id, ok
+}
+
+// hashFiles returns the hex hashes of the files at paths, of their first
+// limit bytes when limit is positive, reading Workers files at once.
+func (h *Hashes) hashFiles(ctx context.Context, opts *Options, paths []string, limit int64) map[string]string {
+	sums := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	workers := h.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				if sum, err := h.hashFile(opts, path, limit); err == nil {
+					mu.Lock()
+					sums[path] = sum
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+	return sums
+}
+
+func (h *Hashes) hashFile(opts *Options, path string, limit int64) (string, error) {
+	var file io.ReadCloser
+	var err error
+	if fs, ok := opts.Fs.(FileOpener); ok {
+		file, err = fs.Open(path)
+	} else {
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if limit > 0 {
+		r = io.LimitReader(file, limit)
+	}
+	sum := h.newHash()
+	if _, err := io.Copy(sum, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// regularFiles returns the regular files of the trees, in order.
+func regularFiles(trees Nodes) Nodes {
+	var files Nodes
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		if node.FileInfo != nil && node.Mode().IsRegular() {
+			files = append(files, node)
+		}
+		for _, nnode := range node.nodes {
+			walk(nnode)
+		}
+	}
+	for _, node := range trees {
+		walk(node)
+	}
+	return files
+}
+
+func init() {
+	RegisterColumn(&funcColumn{
+		name: "hash",
+		value: func(opts *Options, node *Node) (string, bool) {
+			if opts.Hashes == nil {
+				return "", false
+			}
+			return opts.Hashes.Sum(node.path)
+		},
+	})
+	dupe := func(opts *Options, node *Node) (int, bool) {
+		if opts.Hashes == nil {
+			return 0, false
+		}
+		return opts.Hashes.Dupe(node.path)
+	}
+	RegisterColumn(&funcColumn{
+		name: "dupe",
+		value: func(opts *Options, node *Node) (string, bool) {
+			id, ok := dupe(opts, node)
+			return strconv.Itoa(id), ok
+		},
+		text: func(opts *Options, node *Node) (string, bool) {
+			id, ok := dupe(opts, node)
+			return fmt.Sprintf("dupe %d", id), ok
+		},
+	})
+}
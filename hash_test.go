@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+func TestHashes(t *testing.T) {
+	dir := t.TempDir()
+	big := bytes.Repeat([]byte("x"), 2*partialSize)
+	bigger := append(append([]byte(nil), big...), 'y')
+	files := map[string][]byte{
+		"a":       []byte("hello\n"),
+		"b":       []byte("other\n"),
+		"empty":   nil,
+		"empty2":  nil,
+		"s/a":     []byte("hello\n"),
+		"s/big":   big,
+		"s/big2":  big,
+		"s/bigy":  bigger,
+		"s/bigy2": append(append([]byte(nil), big[1:]...), 'y', 'z'),
+	}
+	os.Mkdir(filepath.Join(dir, "s"), 0755)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := &Options{Fs: new(ostree.FS)}
+	tr := New(dir)
+	tr.Visit(opts)
+
+	var sums = []struct {
+		algorithm string
+		expected  string
+	}{
+		{"sha256", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+		{"crc32", "363a3020"},
+		{"xxhash", "e4c191d091bd8853"},
+	}
+	for _, test := range sums {
+		h, err := NewHashes(test.algorithm, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Hash(context.Background(), opts, Nodes{tr})
+		if sum, _ := h.Sum(filepath.Join(dir, "a")); sum != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.algorithm, sum, test.expected)
+		}
+	}
+	for _, algorithm := range []string{"blake2b", "blake3"} {
+		h, _ := NewHashes(algorithm, 1)
+		h.Hash(context.Background(), opts, Nodes{tr})
+		if sum, _ := h.Sum(filepath.Join(dir, "a")); len(sum) != 64 {
+			t.Errorf("%s: got %s", algorithm, sum)
+		}
+	}
+	if _, err := NewHashes("md5", 1); err == nil {
+		t.Error("expected algorithm md5 to be rejected")
+	}
+
+	h, _ := NewHashes("sha256", 2)
+	h.FindDupes(context.Background(), opts, Nodes{tr})
+	var dupes = map[string]int{"a": 1, "s/a": 1, "s/big": 2, "s/big2": 2}
+	for name := range files {
+		id, ok := h.Dupe(filepath.Join(dir, name))
+		if expected, dupe := dupes[name]; ok != dupe || id != expected {
+			t.Errorf("%s: got group %d (%v), expected %d", name, id, ok, expected)
+		}
+	}
+}
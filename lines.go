@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LineCounts are the numbers of lines and words of a text file, or of the
+// text files of a directory.
+type LineCounts struct {
+	Lines int `json:"lines" xml:"lines"`
+	Words int `json:"words,omitempty" xml:"words,omitempty"`
+	// NonBlank are the lines which are not only white space
+	NonBlank int `json:"non_blank,omitempty" xml:"non_blank,omitempty"`
+	// Code are the non-blank lines which are not only comments, counted
+	// for the files of known languages
+	Code int `json:"code,omitempty" xml:"code,omitempty"`
+}
+
+func (c *LineCounts) add(o LineCounts) {
+	c.Lines += o.Lines
+	c.Words += o.Words
+	c.NonBlank += o.NonBlank
+	c.Code += o.Code
+}
+
+// String returns the counts as printed in the footer, leaving out the
+// words and the non-blank and code lines when they are not counted.
+func (c LineCounts) String() string {
+	s := fmt.Sprintf("%d lines", c.Lines)
+	if c.Words != 0 {
+		s += fmt.Sprintf(", %d words", c.Words)
+	}
+	if c.NonBlank != 0 || c.Code != 0 {
+		s += fmt.Sprintf(" (%d non-blank, %d code)", c.NonBlank, c.Code)
+	}
+	return s
+}
+
+// comments are the comment delimiters of a language.
+type comments struct {
+	line       []string
+	start, end string
+}
+
+var (
+	cComments    = comments{line: []string{"//"}, start: "/*", end: "*/"}
+	hashComments = comments{line: []string{"#"}}
+	dashComments = comments{line: []string{"--"}}
+	xmlComments  = comments{start: "<!--", end: "-->"}
+)
+
+// languages are the comment delimiters by file extension.
+var languages = map[string]comments{
+	".c": cComments, ".h": cComments, ".cc": cComments, ".cpp": cComments, ".hpp": cComments,
+	".cs": cComments, ".go": cComments, ".java": cComments, ".js": cComments, ".jsx": cComments,
+	".ts": cComments, ".tsx": cComments, ".kt": cComments, ".rs": cComments, ".scala": cComments,
+	".swift": cComments, ".dart": cComments, ".zig": {line: []string{"//"}},
+	".css": {start: "/*", end: "*/"}, ".scss": cComments, ".php": {line: []string{"//", "#"}, start: "/*", end: "*/"},
+	".py": hashComments, ".rb": hashComments, ".pl": hashComments, ".sh": hashComments,
+	".bash": hashComments, ".zsh": hashComments, ".r": hashComments, ".yaml": hashComments,
+	".yml": hashComments, ".toml": hashComments, ".mk": hashComments, ".nix": hashComments,
+	".sql": dashComments, ".lua": dashComments, ".hs": dashComments, ".elm": dashComments,
+	".html": xmlComments, ".htm": xmlComments, ".xml": xmlComments, ".svg": xmlComments,
+	".el": {line: []string{";"}}, ".clj": {line: []string{";"}}, ".lisp": {line: []string{";"}},
+	".erl": {line: []string{"%"}}, ".tex": {line: []string{"%"}}, ".vim": {line: []string{`"`}},
+}
+
+// languageOf returns the comment delimiters of the language of the file
+// named name.
+func languageOf(name string) (comments, bool) {
+	if base := filepath.Base(name); base == "Makefile" || base == "Dockerfile" {
+		return hashComments, true
+	}
+	lang, ok := languages[strings.ToLower(filepath.Ext(name))]
+	return lang, ok
+}
+
+// maxLine is the length of the start of a line told apart as blank, comment
+// or code; longer lines are still counted whole.
+const maxLine = 1 << 20
+
+// countLines counts the lines and words read from r, and the code lines
+// with the comments of lang when known. It returns false for binary
+// content, which has a NUL byte.
+func countLines(r io.Reader, lang *comments) (counts LineCounts, text bool, err error) {
+	reader := bufio.NewReader(r)
+	var line []byte
+	var inBlock, inWord bool
+	for {
+		fragment, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			return counts, true, nil
+		}
+		if err != nil {
+			return counts, true, err
+		}
+		if bytes.IndexByte(fragment, 0) >= 0 {
+			return LineCounts{}, false, nil
+		}
+		var words int
+		words, inWord = countWords(fragment, inWord)
+		counts.Words += words
+		if n := maxLine - len(line); n > 0 {
+			if n > len(fragment) {
+				n = len(fragment)
+			}
+			line = append(line, fragment[:n]...)
+		}
+		if isPrefix {
+			continue
+		}
+		counts.Lines++
+		rest := strings.TrimSpace(string(line))
+		line, inWord = line[:0], false
+		if rest == "" {
+			continue
+		}
+		counts.NonBlank++
+		if lang == nil {
+			continue
+		}
+		var code bool
+		code, inBlock = isCode(rest, lang, inBlock)
+		if code {
+			counts.Code++
+		}
+	}
+}
+
+// countWords counts the words starting in b, the white space separated
+// runs of other bytes, and tells whether b ends within one. inWord tells
+// whether the bytes before b did.
+func countWords(b []byte, inWord bool) (words int, _ bool) {
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\n', '\v', '\f', '\r':
+			inWord = false
+		default:
+			if !inWord {
+				words++
+			}
+			inWord = true
+		}
+	}
+	return words, inWord
+}
+
+// isCode tells whether the trimmed line holds more than comments, and
+// whether a block comment is still open at its end. Comment delimiters
+// within strings are not told apart.
+func isCode(rest string, lang *comments, inBlock bool) (code, open bool) {
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return code, inBlock
+		}
+		if inBlock {
+			i := strings.Index(rest, lang.end)
+			if i < 0 {
+				return code, true
+			}
+			rest, inBlock = rest[i+len(lang.end):], false
+			continue
+		}
+		// the first comment of the line, if any
+		line, block := len(rest), len(rest)
+		for _, prefix := range lang.line {
+			if i := strings.Index(rest, prefix); i >= 0 && i < line {
+				line = i
+			}
+		}
+		if lang.start != "" {
+			if i := strings.Index(rest, lang.start); i >= 0 {
+				block = i
+			}
+		}
+		if line <= block {
+			return code || line > 0, false
+		}
+		code = code || block > 0
+		rest, inBlock = rest[block+len(lang.start):], true
+	}
+}
+
+// LineStats holds the line counts of the text files of trees, rolled up
+// onto their directories.
+type LineStats struct {
+	// Words shows the words too
+	Words bool
+	// Code shows the non-blank and code lines too
+	Code bool
+	// Total are the counts of all the trees
+	Total  LineCounts
+	counts map[string]LineCounts
+}
+
+// NewLineStats returns empty line counts.
+func NewLineStats(words, code bool) *LineStats {
+	return &LineStats{Words: words, Code: code, counts: make(map[string]LineCounts)}
+}
+
+// Count counts the lines of the regular files of the trees, leaving out
+// the binary ones and those that could not be read.
+func (l *LineStats) Count(ctx context.Context, opts *Options, trees Nodes) {
+	var walk func(node *Node) (LineCounts, bool)
+	walk = func(node *Node) (counts LineCounts, ok bool) {
+		if ctx.Err() != nil || node.FileInfo == nil {
+			return
+		}
+		if node.IsDir() {
+			for _, nnode := range node.nodes {
+				if c, cok := walk(nnode); cok {
+					counts.add(c)
+					ok = true
+				}
+			}
+		} else if node.Mode().IsRegular() && !opts.isCountedOnce(node) {
+			counts, ok = l.countFile(opts, node.path)
+		}
+		if ok {
+			l.counts[node.path] = counts
+		}
+		return
+	}
+	for _, node := range trees {
+		if counts, ok := walk(node); ok {
+			l.Total.add(counts)
+		}
+	}
+}
+
+func (l *LineStats) countFile(opts *Options, path string) (LineCounts, bool) {
+	var file io.ReadCloser
+	var err error
+	if fs, ok := opts.Fs.(FileOpener); ok {
+		file, err = fs.Open(path)
+	} else {
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return LineCounts{}, false
+	}
+	defer file.Close()
+	var lang *comments
+	if c, ok := languageOf(path); ok {
+		lang = &c
+	}
+	counts, text, err := countLines(file, lang)
+	return counts, text && err == nil
+}
+
+// Counts returns the line counts of the file or directory at path.
+func (l *LineStats) Counts(path string) (LineCounts, bool) {
+	counts, ok := l.counts[path]
+	return counts, ok
+}
+
+func init() {
+	for _, column := range []struct {
+		name  string
+		count func(LineCounts) int
+	}{
+		{"lines", func(c LineCounts) int { return c.Lines }},
+		{"words", func(c LineCounts) int { return c.Words }},
+		{"nonblank", func(c LineCounts) int { return c.NonBlank }},
+		{"code", func(c LineCounts) int { return c.Code }},
+	} {
+		column := column
+		value := func(opts *Options, node *Node) (int, bool) {
+			if opts.Lines == nil {
+				return 0, false
+			}
+			counts, ok := opts.Lines.Counts(node.path)
+			return column.count(counts), ok
+		}
+		RegisterColumn(&funcColumn{
+			name: column.name,
+			value: func(opts *Options, node *Node) (string, bool) {
+				n, ok := value(opts, node)
+				return strconv.Itoa(n), ok
+			},
+			text: func(opts *Options, node *Node) (string, bool) {
+				n, ok := value(opts, node)
+				if !ok {
+					return "       ", opts.Lines != nil && node.FileInfo != nil
+				}
+				return fmt.Sprintf("%7d", n), true
+			},
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+func TestCountLines(t *testing.T) {
+	code := `package main
+
+// comment
+/* block
+   comment */
+func main() { /* inline */ }
+/* a */ var x = 1
+
+`
+	var tests = []struct {
+		name     string
+		content  string
+		expected LineCounts
+		text     bool
+	}{
+		{"a.go", code, LineCounts{Lines: 8, Words: 22, NonBlank: 6, Code: 3}, true},
+		{"a.txt", code, LineCounts{Lines: 8, Words: 22, NonBlank: 6}, true},
+		{"a.py", "# comment\nx = 1\n\n  # indented\n", LineCounts{Lines: 4, Words: 7, NonBlank: 3, Code: 1}, true},
+		{"a.c", "x = 1; /* open\n still comment\n */ y()\n", LineCounts{Lines: 3, Words: 9, NonBlank: 3, Code: 2}, true},
+		{"long.go", strings.Repeat("a", 3<<20) + " b\n// c\nd", LineCounts{Lines: 3, Words: 5, NonBlank: 3, Code: 2}, true},
+		{"a.bin", "ab\x00c\n", LineCounts{}, false},
+	}
+	for _, test := range tests {
+		var lang *comments
+		if c, ok := languageOf(test.name); ok {
+			lang = &c
+		}
+		counts, text, err := countLines(strings.NewReader(test.content), lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counts != test.expected || text != test.text {
+			t.Errorf("%s: got %+v (%v), expected %+v (%v)", test.name, counts, text, test.expected, test.text)
+		}
+	}
+}
+
+func TestLineStats(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "s"), 0755)
+	for name, content := range map[string]string{
+		"a.go":   "package a\n\n// doc\nvar A = 1\n",
+		"s/b.sh": "#!/bin/sh\necho b\n",
+		"s/c":    "\x00\x01",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := new(bytes.Buffer)
+	opts := &Options{Fs: new(ostree.FS), OutFile: b}
+	tr := New(dir)
+	tr.Visit(opts)
+	opts.Lines = NewLineStats(true, true)
+	opts.Lines.Count(context.Background(), opts, Nodes{tr})
+	tr.Print(opts)
+	expected := `[      6      11       5       3]  ` + dir + `
+├── [      4       8       3       2]  a.go
+└── [      2       3       2       1]  s
+    ├── [      2       3       2       1]  b.sh
+    └── [                               ]  c
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+	if total := opts.Lines.Total.String(); total != "6 lines, 11 words (5 non-blank, 3 code)" {
+		t.Errorf("wrong total: %s", total)
+	}
+}
@@ -0,0 +1,142 @@
+// Package listtree implements a virtual filesystem out of a list of paths,
+// like the output of find, git ls-files or rg --files.
+package listtree
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FS is a filesystem holding only the listed paths. Paths are listed
+// relative to its root ".", and every parent of a listed path is a
+// directory.
+type FS struct {
+	// Real makes Stat return the metadata of the paths that exist on the
+	// system filesystem, instead of a bare file or directory.
+	Real bool
+
+	entries map[string]*entry
+	roots   []string
+}
+
+type entry struct {
+	name  string
+	dir   bool
+	names []string
+}
+
+// New returns an FS holding only its root.
+func New() *FS {
+	return &FS{
+		entries: map[string]*entry{".": {name: ".", dir: true}},
+		roots:   []string{"."},
+	}
+}
+
+// Roots returns the paths to visit to see every listed path: "." for the
+// relative paths, e.g. ".." for those out of it, and e.g. "/" for the
+// absolute ones.
+func (f *FS) Roots() []string {
+	if len(f.entries["."].names) == 0 && len(f.roots) > 1 {
+		return f.roots[1:]
+	}
+	return f.roots
+}
+
+// Add a path to the filesystem, along with its parents. A path ending with
+// a separator is a directory, others become one as soon as they have a
+// child.
+func (f *FS) Add(path string) {
+	path = strings.TrimRight(path, "\r")
+	if path == "" {
+		return
+	}
+	dir := os.IsPathSeparator(path[len(path)-1])
+	f.add(filepath.Clean(path), dir)
+}
+
+func (f *FS) add(path string, dir bool) {
+	if e, ok := f.entries[path]; ok {
+		e.dir = e.dir || dir
+		return
+	}
+	parent := filepath.Dir(path)
+	if parent == path || filepath.Base(path) == ".." {
+		// the root of absolute paths, or of those out of "." such as
+		// "../a", which are not hidden children of "."
+		f.entries[path] = &entry{name: path, dir: true}
+		f.roots = append(f.roots, path)
+		return
+	}
+	f.add(parent, true)
+	name := filepath.Base(path)
+	f.entries[path] = &entry{name: name, dir: dir}
+	f.entries[parent].names = append(f.entries[parent].names, name)
+}
+
+// AddList adds every path read from r, separated by sep (usually '\n', or
+// 0 for the output of "find -print0").
+func (f *FS) AddList(r io.Reader, sep byte) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, sep); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	for scanner.Scan() {
+		f.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Stat a path
+func (f *FS) Stat(path string) (os.FileInfo, error) {
+	e, ok := f.entries[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	if f.Real {
+		// a listed file can't be shown as a directory without children
+		if fi, err := os.Lstat(path); err == nil && fi.IsDir() == e.dir {
+			return fi, nil
+		}
+	}
+	return fileInfo{e}, nil
+}
+
+// ReadDir reads a directory
+func (f *FS) ReadDir(path string) ([]string, error) {
+	e, ok := f.entries[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	if !e.dir {
+		return nil, &os.PathError{Op: "readdirent", Path: path, Err: syscall.ENOTDIR}
+	}
+	return e.names, nil
+}
+
+type fileInfo struct{ *entry }
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return 0 }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return fi.dir }
+func (fi fileInfo) Sys() interface{}   { return nil }
+func (fi fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
@@ -4,14 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
 
+	"github.com/fiatjaf/tree/listtree"
 	"github.com/fiatjaf/tree/ostree"
 	"github.com/urfave/cli/v3"
 )
 
 func main() {
-	app := &cli.Command{
+	if err := newApp().Run(context.Background(), os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "tree: \"%s\"\n", err)
+		os.Exit(1)
+	}
+}
+
+// newApp returns the tree command.
+func newApp() *cli.Command {
+	// -h is the human readable sizes
+	cli.HelpFlag = &cli.BoolFlag{
+		Name:        "help",
+		Usage:       "show help",
+		HideDefault: true,
+		Local:       true,
+	}
+	return &cli.Command{
 		Name:                   "tree",
 		Usage:                  "List contents of directories in a tree-like format",
 		UseShortOptionHandling: true,
@@ -26,7 +47,36 @@ func main() {
 			&cli.IntFlag{Name: "L", Value: 3, Usage: "Descend only level directories deep"},
 			&cli.StringFlag{Name: "P", Usage: "List only those files that match the pattern given"},
 			&cli.StringFlag{Name: "I", Usage: "Do not list files that match the given pattern"},
+			&cli.BoolFlag{Name: "git", Usage: "Print the git status of each file: S staged, M modified, ? untracked, ! ignored, U conflicted, R renamed"},
+			&cli.StringFlag{Name: "hash", Usage: "Print the content hash of each file: " + strings.Join(HashAlgorithms, ",")},
+			&cli.BoolFlag{Name: "dupes", Usage: "Mark the files with the same content with the id of their group, hashed with --hash or sha256"},
+			&cli.IntFlag{Name: "hash-workers", Value: int64(runtime.NumCPU()), Usage: "Number of files hashed at once by --hash and --dupes"},
+			&cli.BoolFlag{Name: "lines", Usage: "Print the number of lines of each text file, and their total for directories"},
+			&cli.BoolFlag{Name: "words", Usage: "Print the number of words too, implying --lines"},
+			&cli.BoolFlag{Name: "code", Usage: "Print the non-blank and code lines too, telling comments by file extension, implying --lines"},
+			&cli.BoolFlag{Name: "stats", Usage: "Extend the report with counts and sizes by extension and type, the largest, newest, oldest files and the deepest path"},
+			&cli.IntFlag{Name: "stats-top", Value: 10, Usage: "Number of largest files listed by --stats"},
+			&cli.BoolFlag{Name: "x", Usage: "Stay on the current filesystem only, marking the mount points not descended into"},
+			&cli.BoolFlag{Name: "fstype", Usage: "Mark the mount points with their filesystem type (Linux only)"},
+			&cli.BoolFlag{Name: "hardlinks", Usage: "Mark the hard links to files listed before, and count their size and number once"},
+			&cli.BoolFlag{Name: "count-links", Usage: "Count the size and number of each hard link marked by --hardlinks"},
+			&cli.BoolFlag{Name: "git-changed", Usage: "List only the files with changes in git, implies --git"},
 			&cli.StringFlag{Name: "o", Usage: "Output to file instead of stdout"},
+			&cli.BoolFlag{Name: "errors-to-stderr", Usage: "Print errors to stderr instead of in the tree"},
+			&cli.DurationFlag{Name: "timeout", Usage: "Stop visiting after the given duration, e.g. 10s, printing a partial tree and exiting with 2"},
+			&cli.BoolFlag{Name: "strict", Usage: "Exit with 2 when entries could not be read or links are broken"},
+			&cli.BoolFlag{Name: "fromfile", Usage: "Reads paths from files given as arguments ('-' or none for stdin)"},
+			&cli.BoolFlag{Name: "fromfile0", Usage: "Paths read by --fromfile are NUL-separated, as from find -print0"},
+			&cli.StringFlag{Name: "to-fs", Usage: "Create the directories and files described by tree's output, " +
+				"read from files given as arguments ('-' or none for stdin), under the given directory"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "List what --to-fs would create without creating it"},
+			&cli.BoolFlag{Name: "diff", Usage: "Compare the two directories given as arguments"},
+			&cli.StringFlag{Name: "diff-by", Value: "size,mode,mtime", Usage: "Properties compared by --diff and --compare: size,mode,mtime,hash"},
+			&cli.BoolFlag{Name: "pick", Usage: "Browse the tree interactively, and print the picked path"},
+			&cli.StringFlag{Name: "serve", Usage: "Serve a read-only view of the tree over HTTP on the given address, e.g. :8080"},
+			&cli.BoolFlag{Name: "watch", Usage: "Print the tree again each time it changes, highlighting the changes"},
+			&cli.StringFlag{Name: "save", Usage: "Save a snapshot of the tree to the given file"},
+			&cli.StringFlag{Name: "compare", Usage: "Compare the tree to a snapshot saved with --save, exiting with 1 if it changed"},
 
 			// Files options
 			&cli.BoolFlag{Name: "1", Usage: "Print first line of text/plain files"},
@@ -39,6 +89,7 @@ func main() {
 			&cli.BoolFlag{Name: "D", Usage: "Print the date of last modification or (-c) status change"},
 			&cli.BoolFlag{Name: "inodes", Usage: "Print inode number of each file"},
 			&cli.BoolFlag{Name: "device", Usage: "Print device ID number to which each file belongs"},
+			&cli.BoolFlag{Name: "F", Usage: "Appends '/', '=', '*', '@', '|' or '>' as per ls -F"},
 
 			// Sort options
 			&cli.BoolFlag{Name: "U", Usage: "Leave files unsorted"},
@@ -47,13 +98,30 @@ func main() {
 			&cli.BoolFlag{Name: "c", Usage: "Sort files by last status change time"},
 			&cli.BoolFlag{Name: "r", Usage: "Reverse the order of the sort"},
 			&cli.BoolFlag{Name: "dirsfirst", Usage: "List directories before files (-U disables)"},
-			&cli.StringFlag{Name: "sort", Usage: "Select sort: name,version,size,mtime,ctime"},
+			&cli.StringFlag{Name: "sort", Usage: "Select sort keys, comma separated, '-' prefix reverses a key: " +
+				"dirsfirst,ext,name,version,size,mtime,ctime,atime,inode,count; " +
+				"name, version and ext take a collation suffix: :fold,:norm,:locale"},
 
 			// Graphics options
 			&cli.BoolFlag{Name: "i", Usage: "Don't print indentation lines"},
 			&cli.BoolFlag{Name: "C", Usage: "Turn colorization on always"},
+			&cli.StringFlag{Name: "renderer", Value: "text", Usage: "Render the tree with the named renderer, which -X, -J, --table, --graph and --md select: " +
+				strings.Join(RendererNames(), ",")},
+
+			// XML/JSON options
+			&cli.BoolFlag{Name: "X", Usage: "Prints out an XML representation of the tree"},
+			&cli.BoolFlag{Name: "J", Usage: "Prints out a JSON representation of the tree"},
+			&cli.StringFlag{Name: "graph", Usage: "Prints out the tree as a diagram: dot (Graphviz), mermaid (flowchart) or mindmap (Mermaid)"},
+			&cli.BoolFlag{Name: "graph-cluster", Usage: "Draw the contents of each directory of --graph in a box"},
+			&cli.BoolFlag{Name: "graph-count", Usage: "Label the directories of --graph with their number of entries"},
+			&cli.BoolFlag{Name: "ndjson", Usage: "Prints out a JSON object per line for each entry as it is visited, for large trees"},
+			&cli.StringFlag{Name: "table", Usage: "Prints out a row per entry instead of a tree: csv, tsv, or nul (each value followed by a NUL)"},
+			&cli.StringFlag{Name: "columns", Usage: "Columns shown instead of those of the file options, comma separated, path,type,size by default for --table: " +
+				strings.Join(ColumnNames(), ",")},
+			&cli.BoolFlag{Name: "noheader", Usage: "Turn off the header row of --table"},
+			&cli.StringFlag{Name: "md", Usage: "Prints out the tree as Markdown, in a code block (code) or as a list of links (list)"},
 		},
-		Action: func(ctx context.Context, c *cli.Command) error {
+		Action: func(ctx context.Context, c *cli.Command) (err error) {
 			var nd, nf int
 			dirs := []string{"."}
 
@@ -64,7 +132,6 @@ func main() {
 
 			// Output file
 			outFile := os.Stdout
-			var err error
 			if c.String("o") != "" {
 				outFile, err = os.Create(c.String("o"))
 				if err != nil {
@@ -73,14 +140,67 @@ func main() {
 				defer outFile.Close()
 			}
 
+			// Reconstruct a tree from its output
+			if dir := c.String("to-fs"); dir != "" {
+				inputs := []string{"-"}
+				if c.Args().Len() > 0 {
+					inputs = c.Args().Slice()
+				}
+				for _, input := range inputs {
+					if err := createSkeleton(dir, input, c.Bool("dry-run"), outFile); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
 			// Check sort-type
-			if c.String("sort") != "" {
-				switch c.String("sort") {
-				case "version", "mtime", "ctime", "name", "size":
-				default:
-					msg := fmt.Sprintf("sort type '%s' not valid, should be one of: "+
-						"name,version,size,mtime,ctime", c.String("sort"))
-					return errors.New(msg)
+			sortKeys, err := ParseSortKeys(c.String("sort"))
+			if err != nil {
+				return err
+			}
+
+			// Check markdown style
+			if err := checkChoice("markdown style", c.String("md"), MarkdownStyles); err != nil {
+				return err
+			}
+
+			// Check graph format
+			gopts := GraphOptions{Format: c.String("graph"), Cluster: c.Bool("graph-cluster"), Count: c.Bool("graph-count")}
+			if err := checkChoice("graph format", gopts.Format, GraphFormats); err != nil {
+				return err
+			}
+
+			// Check table format and columns
+			if err := checkChoice("table format", c.String("table"), TableFormats); err != nil {
+				return err
+			}
+			var columns []Column
+			if c.IsSet("columns") {
+				if columns, err = ParseColumns(c.String("columns")); err != nil {
+					return err
+				}
+			}
+
+			// Check the number of largest files
+			if top := c.Int("stats-top"); top < 0 {
+				return fmt.Errorf("--stats-top %d not valid, should be 0 or more", top)
+			}
+
+			var errOut io.Writer
+			if c.Bool("errors-to-stderr") {
+				errOut = os.Stderr
+			}
+
+			// Check hash algorithm
+			var hashes *Hashes
+			if c.IsSet("hash") || c.Bool("dupes") {
+				algorithm := c.String("hash")
+				if algorithm == "" {
+					algorithm = "sha256"
+				}
+				if hashes, err = NewHashes(algorithm, int(c.Int("hash-workers"))); err != nil {
+					return err
 				}
 			}
 
@@ -89,6 +209,7 @@ func main() {
 				// Required
 				Fs:      new(ostree.FS),
 				OutFile: outFile,
+				ErrOut:  errOut,
 				// List
 				All:        c.Bool("a"),
 				DirsOnly:   c.Bool("d"),
@@ -98,6 +219,7 @@ func main() {
 				Pattern:    c.String("P"),
 				IPattern:   c.String("I"),
 				IgnoreCase: c.Bool("ignore-case"),
+				OneFS:      c.Bool("x"),
 				// Files
 				Contents: c.Bool("1"),
 				ByteSize: c.Bool("s"),
@@ -109,49 +231,454 @@ func main() {
 				Quotes:   c.Bool("Q"),
 				Inodes:   c.Bool("inodes"),
 				Device:   c.Bool("device"),
+				Classify: c.Bool("F"),
+				Columns:  columns,
 				// Sort
+				Sort:      sortKeys,
 				NoSort:    c.Bool("U"),
 				ReverSort: c.Bool("r"),
 				DirSort:   c.Bool("dirsfirst"),
-				VerSort:   c.Bool("v") || c.String("sort") == "version",
-				ModSort:   c.Bool("t") || c.String("sort") == "mtime",
-				CTimeSort: c.Bool("c") || c.String("sort") == "ctime",
-				NameSort:  c.String("sort") == "name",
-				SizeSort:  c.String("sort") == "size",
+				VerSort:   c.Bool("v"),
+				ModSort:   c.Bool("t"),
+				CTimeSort: c.Bool("c"),
 				// Graphics
 				NoIndent: c.Bool("i"),
 				Colorize: c.Bool("C"),
+				// Renderers
+				Markdown: c.String("md"),
+				Graph:    gopts,
+				Table:    c.String("table"),
+				NoHeader: c.Bool("noheader"),
+			}
+
+			// Renderer, selected by the output options unless given
+			name := c.String("renderer")
+			for _, output := range []struct {
+				set      bool
+				renderer string
+			}{
+				{c.Bool("X"), "xml"},
+				{c.Bool("J"), "json"},
+				{c.String("table") != "", "table"},
+				{gopts.Format != "", "graph"},
+				{c.String("md") != "", "markdown"},
+			} {
+				if !output.set {
+					continue
+				}
+				if c.IsSet("renderer") && name != output.renderer {
+					return fmt.Errorf("--renderer %s conflicts with the %s output", name, output.renderer)
+				}
+				name = output.renderer
+				break
+			}
+			renderer, err := NewRenderer(name, opts)
+			if err != nil {
+				return err
 			}
 
+			// Snapshots are whole unless the depth is given
+			if c.String("save") != "" && !c.IsSet("L") {
+				opts.DeepLevel = 0
+			}
+
+			// Render a list of paths instead of the filesystem
+			if c.Bool("fromfile") || c.Bool("fromfile0") {
+				lfs := listtree.New()
+				// only stat the listed paths when their metadata is shown,
+				// summed up, compared for links and devices or their contents
+				// hashed
+				lfs.Real = opts.Contents || opts.ByteSize || opts.UnitSize || opts.FileMode ||
+					opts.ShowUid || opts.ShowGid || opts.LastMod || opts.Inodes || opts.Device ||
+					opts.Classify || opts.Colorize || opts.Columns != nil || hashes != nil ||
+					c.Bool("stats") || c.Bool("hardlinks") || opts.OneFS
+				sep := byte('\n')
+				if c.Bool("fromfile0") {
+					sep = 0
+				}
+				lists := []string{"-"}
+				if c.Args().Len() > 0 {
+					lists = c.Args().Slice()
+				}
+				for _, list := range lists {
+					if err := readList(lfs, list, sep); err != nil {
+						return err
+					}
+				}
+				opts.Fs = lfs
+				dirs = lfs.Roots()
+			}
+
+			// Filesystem types
+			if c.Bool("fstype") {
+				if opts.MountTypes, err = MountTypes(); err != nil {
+					return err
+				}
+			}
+
+			// Git status
+			if c.Bool("git") || c.Bool("git-changed") {
+				opts.Git = NewGitStatus()
+				opts.GitChanged = c.Bool("git-changed")
+				for _, dir := range dirs {
+					if err := opts.Git.Load(dir); err != nil {
+						return err
+					}
+				}
+			}
+
+			// Browse interactively
+			if c.Bool("pick") {
+				// directories are read when expanded, so only limit the depth when asked
+				if !c.IsSet("L") {
+					opts.DeepLevel = 0
+				}
+				path, ok, err := runPicker(dirs[0], opts)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return cli.Exit("", 1)
+				}
+				fmt.Fprintln(outFile, path)
+				return nil
+			}
+
+			// Serve over HTTP
+			if addr := c.String("serve"); addr != "" {
+				// directories are read when expanded, so only limit the depth when asked
+				if !c.IsSet("L") {
+					opts.DeepLevel = 0
+				}
+				fmt.Fprintf(os.Stderr, "serving %s on %s\n", dirs[0], addr)
+				return NewServer(dirs[0], opts).ListenAndServe(addr)
+			}
+
+			// Watch for changes
+			if c.Bool("watch") {
+				fs, ok := opts.Fs.(*ostree.FS)
+				if !ok {
+					return errors.New("--watch only works on the filesystem")
+				}
+				w, err := fs.NewWatcher()
+				if err != nil {
+					return err
+				}
+				defer w.Close()
+				ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+				defer stop()
+				return Watch(ctx, dirs, opts, w, !c.Bool("noreport"))
+			}
+
+			// Stop on interrupt or timeout, printing what was visited
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+			defer stop()
+			if timeout := c.Duration("timeout"); timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			// Errors and cancellation make a non-zero exit once the output
+			// is printed
+			var errs ErrorCounts
+			var incomplete error
+			defer func() {
+				if err == nil && (incomplete != nil || c.Bool("strict") && errs.Total() > 0) {
+					err = cli.Exit("", 2)
+				}
+			}()
+
+			// Compare two directories
+			if c.Bool("diff") {
+				if len(dirs) != 2 {
+					return errors.New("--diff takes two directories")
+				}
+				by, err := ParseDiffOptions(c.String("diff-by"))
+				if err != nil {
+					return err
+				}
+				// whole trees, unless the depth is given
+				if !c.IsSet("L") {
+					opts.DeepLevel = 0
+				}
+				before, after := New(dirs[0]), New(dirs[1])
+				if _, _, err := before.VisitContext(ctx, opts); err != nil {
+					incomplete = err
+				}
+				if _, _, err := after.VisitContext(ctx, opts); err != nil {
+					incomplete = err
+				}
+				stop()
+				diff := Diff(before, after, opts, by)
+				diff.Print(opts)
+				if !c.Bool("noreport") {
+					fmt.Fprintf(outFile, "\n%s\n", diff.Report())
+				}
+				return nil
+			}
+
+			// Compare to a snapshot
+			if name := c.String("compare"); name != "" {
+				by, err := ParseDiffOptions(c.String("diff-by"))
+				if err != nil {
+					return err
+				}
+				snapshot, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				saved, depth, err := LoadSnapshot(snapshot)
+				snapshot.Close()
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				// as deep as the snapshot, unless asked otherwise
+				if !c.IsSet("L") {
+					opts.DeepLevel = depth
+				}
+				if c.Args().Len() > 0 && c.Args().Len() != len(saved) {
+					return fmt.Errorf("%s holds %d trees, but %d directories were given", name, len(saved), c.Args().Len())
+				}
+				sopts := *opts
+				sopts.Fs = NewEntryFS(saved)
+				var report DiffReport
+				for i, e := range saved {
+					dir := e.Name
+					if c.Args().Len() > 0 {
+						dir = dirs[i]
+					}
+					before, after := New(filepath.Clean(e.Name)), New(dir)
+					before.Visit(&sopts)
+					if _, _, err := after.VisitContext(ctx, opts); err != nil {
+						incomplete = err
+					}
+					diff := Diff(before, after, opts, by)
+					diff.Print(opts)
+					r := diff.Report()
+					report.Added, report.Removed = report.Added+r.Added, report.Removed+r.Removed
+					report.Changed, report.Unchanged = report.Changed+r.Changed, report.Unchanged+r.Unchanged
+				}
+				stop()
+				if !c.Bool("noreport") {
+					fmt.Fprintf(outFile, "\n%s\n", report)
+				}
+				// a partial tree is not told apart from a changed one
+				if incomplete == nil && report.Added+report.Removed+report.Changed > 0 {
+					return cli.Exit("", 1)
+				}
+				return nil
+			}
+
+			// Stream of entries
+			if c.Bool("ndjson") {
+				err := PrintNDJSON(ctx, opts, dirs, !c.Bool("noreport"), &errs)
+				if incomplete = ctx.Err(); err != incomplete {
+					return err
+				}
+				return nil
+			}
+
+			var trees Nodes
+			lines := c.Bool("lines") || c.Bool("words") || c.Bool("code")
+			// hashes, links, lines, stats and snapshots need all the trees
+			// visited, the others are rendered as they are walked when they can
+			keep := c.String("save") != "" || hashes != nil || c.Bool("hardlinks") || lines || c.Bool("stats") ||
+				!opts.streamable(renderer)
 			for _, dir := range dirs {
+				if !keep {
+					d, f, err := renderWalk(ctx, dir, opts, renderer, &errs)
+					nd, nf = nd+d, nf+f
+					if err != nil && err == ctx.Err() {
+						incomplete = err
+						break
+					} else if err != nil {
+						return err
+					}
+					continue
+				}
 				inf := New(dir)
-				d, f := inf.Visit(opts)
+				d, f, err := inf.VisitContext(ctx, opts)
+				if err != nil {
+					incomplete = err
+				}
 				nd, nf = nd+d, nf+f
-				inf.Print(opts)
+				trees = append(trees, inf)
+				errs.Add(inf)
+			}
+
+			// Content hashes
+			if hashes != nil {
+				if c.IsSet("hash") {
+					hashes.Hash(ctx, opts, trees)
+				}
+				if c.Bool("dupes") {
+					hashes.FindDupes(ctx, opts, trees)
+				}
+				opts.Hashes = hashes
+			}
+
+			// Hard links
+			var hardLinks int
+			if c.Bool("hardlinks") {
+				opts.HardLinks = FindHardLinks(trees)
+				opts.CountLinks = c.Bool("count-links")
+				if !opts.CountLinks {
+					hardLinks = opts.HardLinks.Count()
+					nf -= hardLinks
+				}
+			}
+
+			// Line counts
+			if lines {
+				opts.Lines = NewLineStats(c.Bool("words"), c.Bool("code"))
+				opts.Lines.Count(ctx, opts, trees)
+			}
+
+			// Printing is not interrupted by the visit's handler
+			stop()
+
+			// Save a snapshot
+			if name := c.String("save"); name != "" {
+				by, err := ParseDiffOptions(c.String("diff-by"))
+				if err != nil {
+					return err
+				}
+				snapshot, err := os.Create(name)
+				if err != nil {
+					return err
+				}
+				if err := SaveSnapshot(snapshot, opts, trees, by.Hash); err != nil {
+					snapshot.Close()
+					return err
+				}
+				return snapshot.Close()
+			}
+
+			// Render the trees visited whole
+			for _, inf := range trees {
+				if err := Render(opts, inf, renderer); err != nil {
+					return err
+				}
 			}
 
-			// Print footer report
+			// Report of what was visited
+			var report *Report
 			if !c.Bool("noreport") {
-				footer := fmt.Sprintf("\n%d directories", nd)
-				if !opts.DirsOnly {
-					footer += fmt.Sprintf(", %d files", nf)
+				report = NewReport(nd, nf)
+				report.HardLinks = hardLinks
+				if c.Bool("stats") {
+					report.Stats = NewStats(opts, trees, int(c.Int("stats-top")))
+				}
+				if opts.Lines != nil {
+					total := opts.Lines.Total
+					if !opts.Lines.Words {
+						total.Words = 0
+					}
+					if !opts.Lines.Code {
+						total.NonBlank, total.Code = 0, 0
+					}
+					report.Lines = &total
+				}
+				if errs.Total() > 0 {
+					report.Errors = &errs
+				}
+				if errors.Is(incomplete, context.DeadlineExceeded) {
+					report.Incomplete = "timed out"
+				} else if incomplete != nil {
+					report.Incomplete = "interrupted"
 				}
-				fmt.Fprintln(outFile, footer)
 			}
 
-			return nil
+			// Print footer report, and the outputs written once whole
+			return Finish(renderer, report)
 		},
 	}
+}
 
-	cli.HelpFlag = &cli.BoolFlag{
-		Name:        "help",
-		Usage:       "show help",
-		HideDefault: true,
-		Local:       true,
+// readList adds the paths listed in the named file, or stdin for "-", to lfs.
+func readList(lfs *listtree.FS, name string, sep byte) error {
+	if name == "-" {
+		return lfs.AddList(os.Stdin, sep)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
+	return lfs.AddList(file, sep)
+}
 
-	if err := app.Run(context.Background(), os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "tree: \"%s\"\n", err)
-		os.Exit(1)
+// createSkeleton creates under dir the tree described by the named file, or
+// stdin for "-".
+func createSkeleton(dir, name string, dryRun bool, out io.Writer) error {
+	in := os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	trees, err := ParseSkeleton(in)
+	if err != nil {
+		return err
+	}
+	return CreateSkeleton(dir, trees, dryRun, out)
+}
+
+// checkChoice returns an error unless value is empty or one of choices.
+func checkChoice(kind, value string, choices []string) error {
+	if value == "" {
+		return nil
+	}
+	for _, choice := range choices {
+		if value == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s '%s' not valid, should be one of: %s", kind, value, strings.Join(choices, ","))
+}
+
+// renderWalk renders the tree of dir with r as it is walked, returning its
+// number of directories and files as Visit does, and counting its errors
+// to errs.
+func renderWalk(ctx context.Context, dir string, opts *Options, r Renderer, errs *ErrorCounts) (dirs, files int, err error) {
+	w := RenderWalker(r)
+	pre, post := w.Pre, w.Post
+	// the contents of followed links are not counted
+	var links []bool
+	var inLink int
+	w.Pre = func(node *Node) error {
+		if inLink == 0 {
+			errs.count(node.errorKind())
+			if !node.IsDir() {
+				files++
+			} else if node.depth != 0 {
+				dirs++
+			}
+		}
+		link := !node.IsDir() && len(node.nodes) > 0
+		if link {
+			inLink++
+		}
+		links = append(links, link)
+		return pre(node)
+	}
+	w.Post = func(node *Node) error {
+		if links[len(links)-1] {
+			inLink--
+		}
+		links = links[:len(links)-1]
+		return post(node)
+	}
+	w.Error = func(node *Node, err error) error {
+		if inLink == 0 && node.FileInfo == nil {
+			errs.count(node.errorKind())
+		}
+		return r.Error(node, err)
 	}
+	err = Walk(ctx, dir, opts, w)
+	return dirs, files, err
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v3"
+)
+
+// runTree runs the tree command with args, and returns what it wrote to
+// the file of -o.
+func runTree(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out")
+	app := newApp()
+	app.ExitErrHandler = func(context.Context, *cli.Command, error) {}
+	err := app.Run(context.Background(), append([]string{"tree", "-o", out}, args...))
+	b, _ := os.ReadFile(out)
+	return string(b), err
+}
+
+// writeFiles creates the files under dir, by slash-separated path.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiffDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"d1/a/b/c/d/f": "ab",
+		"d2/a/b/c/d/f": "abc",
+	})
+	out, err := runTree(t, "--diff", "--diff-by", "size", filepath.Join(dir, "d1"), filepath.Join(dir, "d2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "[~] f (size 2 → 3)") || !strings.Contains(out, "1 changed") {
+		t.Errorf("change 5 levels deep not found:\n%s", out)
+	}
+
+	// unless the depth is given
+	out, err = runTree(t, "--diff", "--diff-by", "size", "-L", "3", filepath.Join(dir, "d1"), filepath.Join(dir, "d2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "0 changed") {
+		t.Errorf("expected no change within 3 levels:\n%s", out)
+	}
+}
+
+func TestSnapshotColumns(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"d/a": "a", "d/s/b": "bb"})
+	snapshot := filepath.Join(dir, "s.json")
+	d := filepath.Join(dir, "d")
+	if _, err := runTree(t, "--save", snapshot, "--columns", "path,type", d); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runTree(t, "--compare", snapshot, d)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, out)
+	}
+	if !strings.Contains(out, "0 added, 0 removed, 0 changed") {
+		t.Errorf("expected no change:\n%s", out)
+	}
+}
+
+func TestFromFileDupes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"dd/a": "same", "dd/b": "same"})
+	list := filepath.Join(dir, "list")
+	paths := filepath.Join(dir, "dd", "a") + "\n" + filepath.Join(dir, "dd", "b") + "\n"
+	if err := os.WriteFile(list, []byte(paths), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runTree(t, "--fromfile", "--dupes", "-L", "10", list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out, "[dupe 1]") != 2 {
+		t.Errorf("expected a and b to be marked as dupes:\n%s", out)
+	}
+}
+
+func TestFromFileStats(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"dd/a": "abcd"})
+	list := filepath.Join(dir, "list")
+	if err := os.WriteFile(list, []byte(filepath.Join(dir, "dd", "a")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runTree(t, "--fromfile", "--stats", "-L", "10", list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "file           1     4") || strings.Contains(out, "0001-01-01") {
+		t.Errorf("expected the size and time of a:\n%s", out)
+	}
+}
+
+func TestFromFileHardLinks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"dd/a": "abcd"})
+	a, b := filepath.Join(dir, "dd", "a"), filepath.Join(dir, "dd", "b")
+	if err := os.Link(a, b); err != nil {
+		t.Skip(err)
+	}
+	list := filepath.Join(dir, "list")
+	if err := os.WriteFile(list, []byte(a+"\n"+b+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runTree(t, "--fromfile", "--hardlinks", "-L", "10", list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "b [hardlink to "+a+"]") {
+		t.Errorf("expected b to be marked as a link to a:\n%s", out)
+	}
+}
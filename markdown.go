@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MarkdownStyles are the styles of the Markdown output: a fenced code block
+// holding the text output, or a nested list of links.
+var MarkdownStyles = []string{"code", "list"}
+
+// PrintMarkdown prints the trees in the given style, followed by the footer
+// unless it is empty, as Markdown.
+func PrintMarkdown(opts *Options, style string, trees Nodes, footer string) error {
+	switch style {
+	case "code":
+		return printMarkdownCode(opts, trees, footer)
+	case "list":
+		return printMarkdownList(opts, trees, footer)
+	}
+	return fmt.Errorf("markdown style '%s' not valid, should be one of: %s",
+		style, strings.Join(MarkdownStyles, ","))
+}
+
+func printMarkdownCode(opts *Options, trees Nodes, footer string) error {
+	var b bytes.Buffer
+	mopts := *opts
+	mopts.OutFile = &b
+	mopts.Colorize = false
+	for _, node := range trees {
+		node.Print(&mopts)
+	}
+	if footer != "" {
+		fmt.Fprintf(&b, "\n%s\n", footer)
+	}
+	// the fence must be longer than any run of backticks in the block
+	fence, run := 3, 0
+	for _, c := range b.Bytes() {
+		if c != '`' {
+			run = 0
+		} else if run++; run >= fence {
+			fence = run + 1
+		}
+	}
+	_, err := fmt.Fprintf(opts.OutFile, "%s\n%s%s\n", strings.Repeat("`", fence), b.Bytes(), strings.Repeat("`", fence))
+	return err
+}
+
+func printMarkdownList(opts *Options, trees Nodes, footer string) error {
+	var b bytes.Buffer
+	for _, node := range trees {
+		node.markdownItem(&b, "", opts)
+	}
+	if footer != "" {
+		fmt.Fprintf(&b, "\n%s\n", footer)
+	}
+	_, err := opts.OutFile.Write(b.Bytes())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`,
+)
+
+// markdownItem writes the list item of the node, linking to its path, and
+// those of its children.
+func (node *Node) markdownItem(w io.Writer, indent string, opts *Options) {
+	name := node.path
+	if node.depth != 0 && !opts.FullPath {
+		name = filepath.Base(node.path)
+	}
+	if node.err != nil {
+		fmt.Fprintf(w, "%s- %s [%s]\n", indent, markdownEscaper.Replace(name), markdownEscaper.Replace(errMessage(node.err)))
+		return
+	}
+	link := filepath.ToSlash(node.path)
+	if node.IsDir() {
+		name += "/"
+		link = strings.TrimSuffix(link, "/") + "/"
+	}
+	fmt.Fprintf(w, "%s- [%s](%s)", indent, markdownEscaper.Replace(name), markdownLink(link))
+	if node.Mode()&os.ModeSymlink != 0 {
+		if target, err := os.Readlink(node.path); err == nil {
+			fmt.Fprintf(w, " -> %s", markdownEscaper.Replace(target))
+		}
+	}
+	if opts.Contents && !node.IsDir() {
+		if line, hasMore, ok := node.firstLine(); ok {
+			if hasMore {
+				line += "…"
+			}
+			fmt.Fprintf(w, " — %s", markdownEscaper.Replace(line))
+		}
+	}
+	fmt.Fprintln(w)
+	for _, nnode := range node.nodes {
+		nnode.markdownItem(w, indent+"  ", opts)
+	}
+}
+
+// markdownLink escapes each segment of a slash separated path for use as a
+// link destination.
+func markdownLink(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// markdownRenderer renders the trees as Markdown in the style of
+// opts.Markdown, once they are whole.
+type markdownRenderer struct {
+	treeCollector
+	opts   *Options
+	footer string
+}
+
+func (r *markdownRenderer) Report(report *Report) error {
+	r.footer = report.footer(r.opts)
+	return nil
+}
+
+func (r *markdownRenderer) Flush() error {
+	style := r.opts.Markdown
+	if style == "" {
+		style = MarkdownStyles[0]
+	}
+	return PrintMarkdown(r.opts, style, r.trees, r.footer)
+}
+
+func init() {
+	RegisterRenderer("markdown", func(opts *Options) Renderer { return &markdownRenderer{opts: opts} })
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		style    string
+		footer   string
+		expected string
+	}{
+		{"code", "3 directories, 2 files", "```\n" + `ostree/testdata
+├── a
+│   └── b
+│       └── b.txt
+└── c
+    └── c.txt
+
+3 directories, 2 files
+` + "```\n"},
+		{"list", "", `- [ostree/testdata/](ostree/testdata/)
+  - [a/](ostree/testdata/a/)
+    - [b/](ostree/testdata/a/b/)
+      - [b.txt](ostree/testdata/a/b/b.txt)
+  - [c/](ostree/testdata/c/)
+    - [c.txt](ostree/testdata/c/c.txt)
+`},
+	}
+	for _, test := range tests {
+		b := new(bytes.Buffer)
+		tr := New("ostree/testdata")
+		opts := &Options{Fs: new(ostree.FS), OutFile: b}
+		tr.Visit(opts)
+		if err := PrintMarkdown(opts, test.style, Nodes{tr}, test.footer); err != nil {
+			t.Fatal(err)
+		}
+		if actual := b.String(); actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.style, actual, test.expected)
+		}
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "``` [x]_*.md"},
+			{name: "a b#?"},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b}
+	tr.Visit(opts)
+
+	PrintMarkdown(opts, "code", Nodes{tr}, "")
+	expected := "````\nroot\n├── ``` [x]_*.md\n└── a b#?\n````\n"
+	if actual := b.String(); actual != expected {
+		t.Errorf("code:\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+	b.Reset()
+	PrintMarkdown(opts, "list", Nodes{tr}, "")
+	expected = "- [root/](root/)\n  - [\\`\\`\\` \\[x\\]\\_\\*.md](root/%60%60%60%20%5Bx%5D_%2A.md)\n  - [a b#?](root/a%20b%23%3F)\n"
+	if actual := b.String(); actual != expected {
+		t.Errorf("list:\ngot:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
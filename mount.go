@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseMountInfo returns the filesystem types by mount point of the
+// /proc/self/mountinfo format.
+func parseMountInfo(r io.Reader) (map[string]string, error) {
+	types := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// id parent major:minor root mount-point options [optional...] - type source super-options
+		fields := strings.Fields(scanner.Text())
+		for i := 6; i < len(fields)-1; i++ {
+			if fields[i] == "-" && len(fields) > 4 {
+				types[unescapeMount(fields[4])] = fields[i+1]
+				break
+			}
+		}
+	}
+	return types, scanner.Err()
+}
+
+// unescapeMount replaces the octal escapes of spaces, tabs, newlines and
+// backslashes in mount points.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// isMount tells whether the node is a mount point: on another device than
+// the root with the OneFS option, or in MountTypes.
+func (opts *Options) isMount(node *Node) bool {
+	if node.mount {
+		return true
+	}
+	_, ok := opts.fsType(node)
+	return ok
+}
+
+func (opts *Options) fsType(node *Node) (string, bool) {
+	if opts.MountTypes == nil || node.FileInfo == nil || !node.IsDir() {
+		return "", false
+	}
+	fsType, ok := opts.MountTypes[realPath(node.path)]
+	return fsType, ok
+}
+
+func init() {
+	RegisterColumn(NewColumn("mount", func(opts *Options, node *Node) (string, bool) {
+		return "true", opts.isMount(node)
+	}))
+	RegisterColumn(NewColumn("fstype", func(opts *Options, node *Node) (string, bool) {
+		return opts.fsType(node)
+	}))
+}
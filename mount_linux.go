@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package main
+
+import "os"
+
+// MountTypes returns the filesystem types by mount point.
+func MountTypes() (map[string]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
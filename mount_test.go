@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestOneFS(t *testing.T) {
+	root := &file{
+		name: "root",
+		stat: &syscall.Stat_t{Dev: 1},
+		files: []*file{
+			{name: "a", stat: &syscall.Stat_t{Dev: 1}},
+			{name: "mnt", stat: &syscall.Stat_t{Dev: 2}, files: []*file{{name: "b"}}},
+			{name: "sub", stat: &syscall.Stat_t{Dev: 1}, files: []*file{
+				{name: "c", stat: &syscall.Stat_t{Dev: 1}},
+			}},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b, OneFS: true}
+	d, f := tr.Visit(opts)
+	if d != 2 || f != 2 {
+		t.Errorf("expected 2 directories and 2 files, got %d and %d", d, f)
+	}
+	tr.Print(opts)
+	expected := `root
+├── a
+├── mnt [mount point]
+└── sub
+    └── c
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+	trees, err := ParseSkeleton(strings.NewReader(expected + "\n2 directories, 2 files\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := trees[0].Contents[1]; e.Name != "mnt" || e.Type != "directory" {
+		t.Errorf("expected --to-fs to read the mount point as mnt/, got %s (%s)", e.Name, e.Type)
+	}
+}
+
+func TestOneFSSubdir(t *testing.T) {
+	root := &file{
+		name: "root",
+		stat: &syscall.Stat_t{Dev: 1},
+		files: []*file{
+			{name: "sub", stat: &syscall.Stat_t{Dev: 1}, files: []*file{
+				{name: "c", stat: &syscall.Stat_t{Dev: 1}, files: []*file{{name: "d"}}},
+				{name: "mnt", stat: &syscall.Stat_t{Dev: 2}, files: []*file{{name: "e"}}},
+			}},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	// as read by the server, deeper than the root
+	tr := New("root/sub")
+	tr.depth = 1
+	tr.device = 1
+	tr.Visit(&Options{Fs: fs, OneFS: true, DeepLevel: 3})
+	if tr.mount || len(tr.nodes) != 2 {
+		t.Fatalf("expected root/sub to be read, got mount %v and %d entries", tr.mount, len(tr.nodes))
+	}
+	for _, node := range tr.nodes {
+		if mount := node.Name() == "mnt"; node.mount != mount {
+			t.Errorf("%s: expected mount %v", node.path, mount)
+		}
+	}
+}
+
+func TestServerOneFS(t *testing.T) {
+	root := &file{
+		name: "root",
+		stat: &syscall.Stat_t{Dev: 1},
+		files: []*file{
+			{name: "sub", stat: &syscall.Stat_t{Dev: 1}, files: []*file{
+				{name: "mnt", stat: &syscall.Stat_t{Dev: 3}, files: []*file{{name: "f"}}},
+			}},
+			{name: "mnt", stat: &syscall.Stat_t{Dev: 2}, files: []*file{{name: "e"}}},
+		},
+	}
+	fs := openerFs{&countingFs{MockFs: NewFs().addFile(root.name, root)}}
+	s := NewServer(root.name, &Options{Fs: fs, OneFS: true})
+	tests := []struct {
+		url      string
+		code     int
+		expected string
+	}{
+		{"/api/tree?path=sub", 200, `{"type":"directory","name":"sub","contents":[{"type":"directory","name":"mnt","columns":{"mount":"true"}}]}`},
+		{"/api/tree?path=mnt", 403, ""},
+		{"/api/tree?path=sub/mnt", 403, ""},
+		{"/files/mnt/e", 403, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if rec.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.url, test.code, rec.Code)
+			continue
+		}
+		if actual := strings.TrimSpace(rec.Body.String()); test.expected != "" && actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.url, actual, test.expected)
+		}
+	}
+}
+
+func TestParseMountInfo(t *testing.T) {
+	info := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+23 22 0:21 / /proc rw,nosuid master:2 - proc proc rw
+24 22 8:2 / /mnt/my\040disk rw - vfat /dev/sdb1 rw
+`
+	types, err := parseMountInfo(strings.NewReader(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"/": "ext4", "/proc": "proc", "/mnt/my disk": "vfat"}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("got %v, expected %v", types, expected)
+	}
+}
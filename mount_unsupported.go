@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+// MountTypes returns the filesystem types by mount point, only known on
+// Linux.
+func MountTypes() (map[string]string, error) {
+	return nil, errors.New("filesystem types are only known on Linux")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+)
+
+// entryEvent is the line written by PrintNDJSON for each node, and for
+// each node whose error is set.
+type entryEvent struct {
+	Event  string `json:"event"`
+	Path   string `json:"path"`
+	Parent string `json:"parent,omitempty"`
+	Depth  int    `json:"depth"`
+	*Entry
+}
+
+// summaryEvent is the last line written by PrintNDJSON.
+type summaryEvent struct {
+	Event       string `json:"event"`
+	Directories int    `json:"directories"`
+	Files       int    `json:"files"`
+	Errors      int    `json:"errors"`
+	Incomplete  bool   `json:"incomplete,omitempty"`
+}
+
+// PrintNDJSON prints the trees of dirs as a stream of JSON objects, one
+// per line: an "entry" event for each node as it is visited, followed by
+// an "error" event when it could not be read or is a broken link, and a
+// final "summary" event unless report is false. Directories are read one
+// at a time, so their sizes are not included. Errors are counted in errs.
+// Each event is written as soon as it is made. The walk stops when ctx is
+// done, and its error is then returned once the summary is printed.
+func PrintNDJSON(ctx context.Context, opts *Options, dirs []string, report bool, errs *ErrorCounts) error {
+	enc := json.NewEncoder(opts.OutFile)
+	summary := summaryEvent{Event: "summary"}
+	var err error
+	emit := func(v interface{}) {
+		if err == nil {
+			err = enc.Encode(v)
+		}
+	}
+	event := func(node *Node, name string, e *Entry) entryEvent {
+		ev := entryEvent{Event: name, Path: node.path, Depth: node.depth, Entry: e}
+		if node.depth != 0 {
+			ev.Parent = filepath.Dir(node.path)
+		}
+		return ev
+	}
+	onError := func(node *Node, kind string) {
+		summary.Errors++
+		errs.count(kind)
+		e := &Entry{Type: node.Type(), Name: filepath.Base(node.path), Error: node.errorText(), ErrorKind: kind}
+		if node.depth == 0 || opts.FullPath {
+			e.Name = node.path
+		}
+		emit(event(node, "error", e))
+	}
+	w := Walker{
+		Pre: func(node *Node) error {
+			// the contents of the node are in events of their own
+			shallow := *node
+			shallow.nodes = nil
+			e := shallow.Entry(opts)
+			e.Error, e.ErrorKind = "", ""
+			if node.IsDir() {
+				e.Size = nil
+				if node.depth != 0 {
+					summary.Directories++
+				}
+			} else {
+				summary.Files++
+			}
+			emit(event(node, "entry", e))
+			if node.brokenLink {
+				onError(node, ErrBrokenLink)
+			}
+			return err
+		},
+		Error: func(node *Node, _ error) error {
+			onError(node, node.errorKind())
+			return err
+		},
+	}
+	for _, dir := range dirs {
+		if werr := Walk(ctx, dir, opts, w); werr != nil && werr != ctx.Err() {
+			return werr
+		}
+	}
+	summary.Incomplete = ctx.Err() != nil
+	if report {
+		emit(summary)
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Mock filesystem failing to read some directories
+type unreadableFs struct {
+	*MockFs
+	unreadable map[string]bool
+}
+
+func (fs *unreadableFs) ReadDir(path string) ([]string, error) {
+	if fs.unreadable[path] {
+		return nil, &os.PathError{Op: "open", Path: path, Err: errors.New("permission denied")}
+	}
+	return fs.MockFs.ReadDir(path)
+}
+
+func TestNDJSON(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 5},
+			{name: "bad"},
+			{name: "b", files: []*file{
+				{name: "c", files: []*file{{name: "d", size: 1}}},
+			}},
+			{name: "locked", files: []*file{{name: "x"}}},
+		},
+	}
+	fs := &unreadableFs{NewFs().addFile(root.name, root), map[string]bool{"root/locked": true}}
+	b := new(bytes.Buffer)
+	opts := &Options{Fs: fs, OutFile: b, ByteSize: true}
+	var errs ErrorCounts
+	if err := PrintNDJSON(context.Background(), opts, []string{root.name}, true, &errs); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"event":"entry","path":"root","depth":0,"type":"directory","name":"root"}
+{"event":"entry","path":"root/a","parent":"root","depth":1,"type":"file","name":"a","size":5}
+{"event":"entry","path":"root/b","parent":"root","depth":1,"type":"directory","name":"b"}
+{"event":"entry","path":"root/b/c","parent":"root/b","depth":2,"type":"directory","name":"c"}
+{"event":"entry","path":"root/b/c/d","parent":"root/b/c","depth":3,"type":"file","name":"d","size":1}
+{"event":"entry","path":"root/locked","parent":"root","depth":1,"type":"directory","name":"locked"}
+{"event":"error","path":"root/locked","parent":"root","depth":1,"type":"directory","name":"locked","error":"permission denied","error_kind":"unreadable"}
+{"event":"error","path":"root/bad","parent":"root","depth":1,"type":"error","name":"bad","error":"stat failed","error_kind":"stat"}
+{"event":"summary","directories":3,"files":2,"errors":2}
+`
+	if actual := b.String(); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+	if errs != (ErrorCounts{Unreadable: 1, StatFailures: 1}) {
+		t.Errorf("wrong error counts: %+v", errs)
+	}
+}
+
+// Writer of the lines written at once
+type lineWriter struct {
+	lines []string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.lines = append(w.lines, string(p))
+	return len(p), nil
+}
+
+func TestNDJSONStream(t *testing.T) {
+	root := &file{name: "root", files: []*file{{name: "a"}, {name: "b", files: []*file{{name: "c"}}}}}
+	w := new(lineWriter)
+	opts := &Options{Fs: NewFs().addFile(root.name, root), OutFile: w}
+	if err := PrintNDJSON(context.Background(), opts, []string{root.name}, true, new(ErrorCounts)); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.lines) != 5 {
+		t.Fatalf("expected 5 writes, one per event, got %d: %q", len(w.lines), w.lines)
+	}
+	for _, line := range w.lines {
+		if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+			t.Errorf("expected a single event per write, got %q", line)
+		}
+	}
+}
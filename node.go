@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +27,17 @@ type Node struct {
 	err    error
 	nodes  Nodes
 	vpaths map[string]bool
+	// incomplete is set on the directories whose visit was cancelled
+	incomplete bool
+	// recursive is set on links to a directory being visited, which are
+	// not followed
+	recursive bool
+	// device is the device of the root with the OneFS option, and mount
+	// is set on the directories on another one, which are not visited
+	device uint64
+	mount  bool
+	// brokenLink is set on the links to nothing
+	brokenLink bool
 }
 
 // List of nodes
@@ -44,6 +56,10 @@ type Fs interface {
 type Options struct {
 	Fs      Fs
 	OutFile io.Writer
+	// ErrOut, when set, receives the errors met while printing instead
+	// of the tree, which then leaves out the entries that could not be
+	// stat'ed.
+	ErrOut io.Writer
 	// List
 	All        bool
 	DirsOnly   bool
@@ -55,6 +71,10 @@ type Options struct {
 	IPattern   string
 	MatchDirs  bool
 	Prune      bool
+	// OneFS stays on the filesystem of the root.
+	OneFS bool
+	// GitChanged leaves out the entries without changes in Git.
+	GitChanged bool
 	// File
 	Contents bool
 	ByteSize bool
@@ -66,7 +86,26 @@ type Options struct {
 	Quotes   bool
 	Inodes   bool
 	Device   bool
+	Classify bool
+	// Columns, when set, are the columns shown instead of those of the
+	// File options above.
+	Columns []Column
+	// Git, when set, is the status of the entries in Git.
+	Git *GitStatus
+	// Hashes, when set, are the content hashes of the files.
+	Hashes *Hashes
+	// HardLinks, when set, are the files sharing their inode, whose size
+	// is only counted once unless CountLinks is set.
+	HardLinks  *HardLinks
+	CountLinks bool
+	// Lines, when set, are the line counts of the text files.
+	Lines *LineStats
+	// MountTypes, when set, are the filesystem types by mount point.
+	MountTypes map[string]string
 	// Sort
+	// Sort holds the keys of a multi-key sort, the boolean options
+	// below are used when it is empty.
+	Sort      []SortKey
 	NoSort    bool
 	VerSort   bool
 	ModSort   bool
@@ -81,6 +120,15 @@ type Options struct {
 	// Color defaults to ANSIColor()
 	Color func(*Node, string) string
 	Now   time.Time
+	// Renderers
+	// Markdown is the style of the markdown renderer, "code" when empty.
+	Markdown string
+	// Graph configures the graph renderer, in the dot format when empty.
+	Graph GraphOptions
+	// Table is the format of the table renderer, "csv" when empty, whose
+	// header row is turned off by NoHeader.
+	Table    string
+	NoHeader bool
 }
 
 func (opts *Options) color(node *Node, s string) string {
@@ -91,6 +139,43 @@ func (opts *Options) color(node *Node, s string) string {
 	return f(node, s)
 }
 
+// sortKeys returns the sort keys in effect, combining Sort with the
+// DirSort and ReverSort options.
+func (opts *Options) sortKeys() []SortKey {
+	keys := opts.Sort
+	if len(keys) == 0 {
+		var name string
+		switch {
+		case opts.ModSort:
+			name = "mtime"
+		case opts.CTimeSort:
+			name = "ctime"
+		case opts.VerSort:
+			name = "version"
+		case opts.SizeSort:
+			name = "size"
+		default:
+			name = "name" // Default should be sorted, not unsorted.
+		}
+		keys = []SortKey{{Name: name}}
+	}
+	if opts.DirSort && keys[0].Name != "dirsfirst" {
+		keys = append([]SortKey{{Name: "dirsfirst"}}, keys...)
+	}
+	// directories stay first with -r
+	if opts.ReverSort {
+		reversed := make([]SortKey, len(keys))
+		for i, key := range keys {
+			reversed[i] = key
+			if key.Name != "dirsfirst" {
+				reversed[i].Reverse = !key.Reverse
+			}
+		}
+		keys = reversed
+	}
+	return keys
+}
+
 // New get path and create new node(root).
 func New(path string) *Node {
 	return &Node{path: path, vpaths: make(map[string]bool)}
@@ -98,6 +183,22 @@ func New(path string) *Node {
 
 // Visit all files under the given node.
 func (node *Node) Visit(opts *Options) (dirs, files int) {
+	dirs, files, _ = node.VisitContext(context.Background(), opts)
+	return
+}
+
+// VisitContext visits all files under the given node until ctx is done, in
+// which case it returns the error of ctx, and the directories left partly
+// visited are marked as incomplete.
+func (node *Node) VisitContext(ctx context.Context, opts *Options) (dirs, files int, err error) {
+	dirs, files = node.visit(ctx, opts)
+	if node.incomplete {
+		err = ctx.Err()
+	}
+	return
+}
+
+func (node *Node) visit(ctx context.Context, opts *Options) (dirs, files int) {
 	// visited paths
 	if path, err := filepath.Abs(node.path); err == nil {
 		path = filepath.Clean(path)
@@ -111,12 +212,25 @@ func (node *Node) Visit(opts *Options) (dirs, files int) {
 	}
 	node.FileInfo = fi
 	if !fi.IsDir() {
+		node.brokenLink = opts.isBrokenLink(node)
 		return 0, 1
 	}
 	// increase dirs only if it's a dir, but not the root.
 	if node.depth != 0 {
 		dirs++
 	}
+	// OneFS option, stay on the device of the root, given to the
+	// subdirectories read by the server
+	if opts.OneFS {
+		if ok, _, device, _, _ := getStat(node); ok {
+			if node.depth == 0 {
+				node.device = device
+			} else if device != node.device {
+				node.mount = true
+				return
+			}
+		}
+	}
 	// DeepLevel option
 	if opts.DeepLevel > 0 && opts.DeepLevel <= node.depth {
 		return
@@ -138,6 +252,10 @@ func (node *Node) Visit(opts *Options) (dirs, files int) {
 	}
 	node.nodes = make(Nodes, 0)
 	for _, name := range names {
+		if ctx.Err() != nil {
+			node.incomplete = true
+			break
+		}
 		// "all" option
 		if !opts.All && strings.HasPrefix(name, ".") {
 			continue
@@ -146,14 +264,21 @@ func (node *Node) Visit(opts *Options) (dirs, files int) {
 			path:   filepath.Join(node.path, name),
 			depth:  node.depth + 1,
 			vpaths: node.vpaths,
+			device: node.device,
+		}
+		d, f := nnode.visit(ctx, opts)
+		if nnode.incomplete {
+			node.incomplete = true
 		}
-		d, f := nnode.Visit(opts)
 		if nnode.err == nil {
 			if nnode.IsDir() {
 				// "prune" option, hide empty directories
 				if opts.Prune && f == 0 {
 					continue
 				}
+				if opts.GitChanged && !opts.Git.Changed(nnode.path) {
+					continue
+				}
 				if opts.MatchDirs && opts.IPattern != "" && nnode.match(opts.IPattern, opts) {
 					continue
 				}
@@ -170,6 +295,9 @@ func (node *Node) Visit(opts *Options) (dirs, files int) {
 				if opts.IPattern != "" && nnode.match(opts.IPattern, opts) {
 					continue
 				}
+				if opts.GitChanged && !opts.Git.Changed(nnode.path) {
+					continue
+				}
 			}
 		}
 		node.nodes = append(node.nodes, nnode)
@@ -196,30 +324,7 @@ func (node *Node) match(pattern string, opt *Options) bool {
 }
 
 func (node *Node) sort(opts *Options) {
-	var fn SortFunc
-	switch {
-	case opts.ModSort:
-		fn = ModSort
-	case opts.CTimeSort:
-		fn = CTimeSort
-	case opts.DirSort:
-		fn = DirSort
-	case opts.VerSort:
-		fn = VerSort
-	case opts.SizeSort:
-		fn = SizeSort
-	case opts.NameSort:
-		fn = NameSort
-	default:
-		fn = NameSort // Default should be sorted, not unsorted.
-	}
-	if fn != nil {
-		if opts.ReverSort {
-			sort.Sort(sort.Reverse(ByFunc{node.nodes, fn}))
-		} else {
-			sort.Sort(ByFunc{node.nodes, fn})
-		}
-	}
+	sort.Stable(&ByKeys{Nodes: node.nodes, Keys: opts.sortKeys()})
 }
 
 // Path returns the Node's absolute path
@@ -227,8 +332,57 @@ func (node *Node) Path() string {
 	return node.path
 }
 
-// Print nodes based on the given configuration.
-func (node *Node) Print(opts *Options) { node.print("", opts) }
+// Type returns the kind of the node, as named by tree's structured
+// outputs: "directory", "file", "link", "fifo", "socket", "char",
+// "block" or "door". It returns "error" if the node could not be read.
+func (node *Node) Type() string {
+	if node.FileInfo == nil {
+		return "error"
+	}
+	mode := node.Mode()
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return "link"
+	case node.IsDir():
+		return "directory"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char"
+	case mode&os.ModeDevice != 0:
+		return "block"
+	case isDoor(node.FileInfo):
+		return "door"
+	}
+	return "file"
+}
+
+// classify returns the indicator appended to the name of the node by the
+// Classify option, like "ls -F".
+func (node *Node) classify() string {
+	switch node.Type() {
+	case "directory":
+		return "/"
+	case "link":
+		return "@"
+	case "fifo":
+		return "|"
+	case "socket":
+		return "="
+	case "door":
+		return ">"
+	case "file":
+		if node.Mode()&modeExecute != 0 {
+			return "*"
+		}
+	}
+	return ""
+}
+
+// Print nodes based on the given configuration, with the text renderer.
+func (node *Node) Print(opts *Options) { Render(opts, node, NewTextRenderer(opts)) }
 
 func dirRecursiveSize(opts *Options, node *Node) (size int64, err error) {
 	if opts.DeepLevel > 0 && node.depth >= opts.DeepLevel {
@@ -242,7 +396,9 @@ func dirRecursiveSize(opts *Options, node *Node) (size int64, err error) {
 		}
 
 		if !nnode.IsDir() {
-			size += nnode.Size()
+			if !opts.isCountedOnce(nnode) {
+				size += nnode.Size()
+			}
 		} else {
 			nsize, e := dirRecursiveSize(opts, nnode)
 			size += nsize
@@ -254,196 +410,67 @@ func dirRecursiveSize(opts *Options, node *Node) (size int64, err error) {
 	return
 }
 
-var reusable = make([]byte, 60)
-
-func (node *Node) print(indent string, opts *Options) {
-	if node.err != nil {
-		err := node.err.Error()
-		if msgs := strings.Split(err, ": "); len(msgs) > 1 {
-			err = msgs[1]
-		}
-		name := node.path
-		if !opts.FullPath {
-			name = filepath.Base(name)
-		}
-		fmt.Fprintf(opts.OutFile, "%s [%s]\n", name, err)
-		return
+// errMessage returns the message of err without the operation and path
+// prefixes of os errors.
+func errMessage(err error) string {
+	var perr *os.PathError
+	if errors.As(err, &perr) {
+		return perr.Err.Error()
 	}
-	if !node.IsDir() {
-		var props []string
-		ok, inode, device, uid, gid := getStat(node)
-		// inodes
-		if ok && opts.Inodes {
-			props = append(props, fmt.Sprintf("%d", inode))
-		}
-		// device
-		if ok && opts.Device {
-			props = append(props, fmt.Sprintf("%3d", device))
-		}
-		// Mode
-		if opts.FileMode {
-			props = append(props, node.Mode().String())
-		}
-		// Owner/Uid
-		if ok && opts.ShowUid {
-			uidStr := strconv.Itoa(int(uid))
-			if u, err := user.LookupId(uidStr); err != nil {
-				props = append(props, fmt.Sprintf("%-8s", uidStr))
-			} else {
-				props = append(props, fmt.Sprintf("%-8s", u.Username))
-			}
-		}
-		// Gorup/Gid
-		// TODO: support groupname
-		if ok && opts.ShowGid {
-			gidStr := strconv.Itoa(int(gid))
-			props = append(props, fmt.Sprintf("%-4s", gidStr))
-		}
-		// Size
-		if opts.ByteSize || opts.UnitSize {
-			var size string
-			if opts.UnitSize {
-				size = fmt.Sprintf("%4s", formatBytes(node.Size()))
-			} else {
-				size = fmt.Sprintf("%11d", node.Size())
-			}
-			props = append(props, size)
-		}
-		// Last modification
-		if opts.LastMod {
-			t := opts.Now
-			if t.IsZero() {
-				t = time.Now()
-			}
-
-			format := "Jan 02 15:04"
-			if node.ModTime().Year() != t.Year() {
-				format = "Jan 02  2006"
-			}
-
-			props = append(props, node.ModTime().Format(format))
-		}
-		// Print properties
-		if len(props) > 0 {
-			fmt.Fprintf(opts.OutFile, "[%s]  ", strings.Join(props, " "))
-		}
-	} else {
-		var props []string
-		// Size
-		if opts.ByteSize || opts.UnitSize {
-			var size string
-			rsize, err := dirRecursiveSize(opts, node)
-			if err != nil && rsize <= 0 {
-				if opts.UnitSize {
-					size = "    "
-				} else {
-					size = "           "
-				}
-			} else if opts.UnitSize {
-				size = fmt.Sprintf("%4s", formatBytes(rsize))
-			} else {
-				size = fmt.Sprintf("%11d", rsize)
-			}
-			props = append(props, size)
-		}
-		// Print properties
-		if len(props) > 0 {
-			fmt.Fprintf(opts.OutFile, "[%s]  ", strings.Join(props, " "))
-		}
+	var lerr *os.LinkError
+	if errors.As(err, &lerr) {
+		return lerr.Err.Error()
 	}
-	// name/path
-	var name string
-	if node.depth == 0 || opts.FullPath {
-		name = node.path
-	} else {
-		name = node.Name()
-	}
-	// Quotes
-	if opts.Quotes {
-		name = fmt.Sprintf("\"%s\"", name)
-	}
-	// Colorize
-	if opts.Colorize {
-		name = opts.color(node, name)
-	}
-	// IsSymlink
-	if node.Mode()&os.ModeSymlink == os.ModeSymlink {
-		vtarget, err := os.Readlink(node.path)
-		if err != nil {
-			vtarget = node.path
-		}
-		targetPath, err := filepath.EvalSymlinks(node.path)
-		if err != nil {
-			targetPath = vtarget
-		}
-		fi, err := opts.Fs.Stat(targetPath)
-		if opts.Colorize && fi != nil {
-			vtarget = opts.color(&Node{FileInfo: fi, path: vtarget}, vtarget)
-		}
-		name = fmt.Sprintf("%s -> %s", name, vtarget)
-		// Follow symbolic links like directories
-		if opts.FollowLink {
-			path, err := filepath.Abs(targetPath)
-			if err == nil && fi != nil && fi.IsDir() {
-				if _, ok := node.vpaths[filepath.Clean(path)]; !ok {
-					inf := &Node{FileInfo: fi, path: targetPath}
-					inf.vpaths = node.vpaths
-					inf.Visit(opts)
-					node.nodes = inf.nodes
-				} else {
-					name += " [recursive, not followed]"
-				}
-			}
-		}
+	var serr *os.SyscallError
+	if errors.As(err, &serr) {
+		return serr.Err.Error()
 	}
-	// Print file name/details
-	// the main idea of the print logic came from here: github.com/campoy/tools/tree
-	fmt.Fprint(opts.OutFile, name)
-
-	// Print first line of content
-	if opts.Contents {
-		mime, _ := exec.Command("file", "--mime-type", "--brief", "-P", "bytes=200", node.path).Output()
-		if unsafe.String(unsafe.SliceData(mime), len(mime)-1) == "text/plain" {
-			if file, err := os.Open(node.path); err == nil {
-				if n, err := file.Read(reusable); err == nil {
-					if firstNewline := bytes.IndexAny(reusable[0:n], "\n\r"); firstNewline != -1 {
-						n = firstNewline
-					}
-					hasMore := n == 60
-					if hasMore {
-						n = 59
-					}
+	return err.Error()
+}
 
-					fmt.Fprintf(opts.OutFile, " => `")
-					opts.OutFile.Write(reusable[0:n])
+// userName returns the name of the user with the given uid, or the uid.
+func userName(uid uint64) string {
+	uidStr := strconv.FormatUint(uid, 10)
+	if u, err := user.LookupId(uidStr); err == nil {
+		return u.Username
+	}
+	return uidStr
+}
 
-					if hasMore {
-						fmt.Fprintf(opts.OutFile, "…`")
-					} else {
-						fmt.Fprintf(opts.OutFile, "`")
-					}
-				}
-				file.Close()
-			}
-		}
+// groupName returns the name of the group, or its gid when unknown.
+func groupName(gid uint64) string {
+	gidStr := strconv.FormatUint(gid, 10)
+	if g, err := user.LookupGroupId(gidStr); err == nil {
+		return g.Name
 	}
-	fmt.Fprintln(opts.OutFile, "")
+	return gidStr
+}
 
-	// tree stuff
-	add := "│   "
-	for i, nnode := range node.nodes {
-		if opts.NoIndent {
-			add = ""
-		} else {
-			if i == len(node.nodes)-1 {
-				fmt.Fprintf(opts.OutFile, indent+"└── ")
-				add = "    "
-			} else {
-				fmt.Fprintf(opts.OutFile, indent+"├── ")
-			}
-		}
-		nnode.print(indent+add, opts)
+// firstLine returns the first line of a text/plain file, cut to 59 bytes
+// if it is longer, in which case hasMore is true.
+func (node *Node) firstLine() (line string, hasMore, ok bool) {
+	mime, _ := exec.Command("file", "--mime-type", "--brief", "-P", "bytes=200", node.path).Output()
+	if len(mime) == 0 || unsafe.String(unsafe.SliceData(mime), len(mime)-1) != "text/plain" {
+		return "", false, false
+	}
+	file, err := os.Open(node.path)
+	if err != nil {
+		return "", false, false
+	}
+	defer file.Close()
+	buf := make([]byte, 60)
+	n, err := file.Read(buf)
+	if err != nil {
+		return "", false, false
+	}
+	if firstNewline := bytes.IndexAny(buf[0:n], "\n\r"); firstNewline != -1 {
+		n = firstNewline
+	}
+	hasMore = n == 60
+	if hasMore {
+		n = 59
 	}
+	return string(buf[0:n]), hasMore, true
 }
 
 const (
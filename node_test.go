@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"os"
 	"syscall"
@@ -298,14 +299,44 @@ var sortTests = []treeTest{
 	{"dirs-first sort", &Options{Fs: fs, OutFile: out, DirSort: true}, `root
 ├── c
 │   └── d
+├── a
+└── b
+`, 1, 3},
+	{"dirs-first + last-mod sort", &Options{Fs: fs, OutFile: out, DirSort: true, ModSort: true, ReverSort: true}, `root
+├── c
+│   └── d
 ├── b
 └── a
 `, 1, 3},
-	{"reverse sort", &Options{Fs: fs, OutFile: out, ReverSort: true, DirSort: true}, `root
+	{"multi-key sort", &Options{Fs: fs, OutFile: out, Sort: []SortKey{{Name: "dirsfirst"}, {Name: "size", Reverse: true}}}, `root
+├── c
+│   └── d
 ├── b
+└── a
+`, 1, 3},
+	{"multi-key reversed dirs-first", &Options{Fs: fs, OutFile: out, Sort: []SortKey{{Name: "dirsfirst", Reverse: true}, {Name: "name"}}}, `root
 ├── a
+├── b
 └── c
     └── d
+`, 1, 3},
+	{"count sort", &Options{Fs: fs, OutFile: out, Sort: []SortKey{{Name: "count", Reverse: true}, {Name: "mtime", Reverse: true}}}, `root
+├── c
+│   └── d
+├── b
+└── a
+`, 1, 3},
+	{"reverse sort", &Options{Fs: fs, OutFile: out, ReverSort: true, DirSort: true}, `root
+├── c
+│   └── d
+├── b
+└── a
+`, 1, 3},
+	{"reversed multi-key sort", &Options{Fs: fs, OutFile: out, ReverSort: true, Sort: []SortKey{{Name: "dirsfirst"}, {Name: "size"}}}, `root
+├── c
+│   └── d
+├── b
+└── a
 `, 1, 3},
 	{"no-sort", &Options{Fs: fs, OutFile: out, NoSort: true, DirSort: true}, `root
 ├── b
@@ -359,6 +390,25 @@ func TestSort(t *testing.T) {
 	}
 }
 
+func TestParseSortKeys(t *testing.T) {
+	keys, err := ParseSortKeys("dirsfirst, -size,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SortKey{{Name: "dirsfirst"}, {Name: "size", Reverse: true}, {Name: "name"}}
+	if len(keys) != len(expected) {
+		t.Fatalf("got %v, expected %v", keys, expected)
+	}
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Errorf("got %v, expected %v", keys, expected)
+		}
+	}
+	if _, err := ParseSortKeys("name,bogus"); err == nil {
+		t.Errorf("expected an error for an unknown sort key")
+	}
+}
+
 var graphicTests = []treeTest{
 	{"no-indent", &Options{Fs: fs, OutFile: out, NoIndent: true}, `root
 a
@@ -389,6 +439,11 @@ c
 ├── [-rw-r--r--]  a
 ├── [-rwxr-xr-x]  b
 └── [-rw-rw-rw-]  c
+`, 0, 3},
+	{"classify", &Options{Fs: fs, OutFile: out, Classify: true}, `root
+├── a
+├── b*
+└── c
 `, 0, 3},
 	{"lastMod", &Options{Fs: fs, OutFile: out, LastMod: true, Now: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)}, `root
 ├── [Feb 11 00:00]  a
@@ -430,6 +485,9 @@ var symlinkTests = []treeTest{
 `, 0, 1},
 	{"symlink-rec", &Options{Fs: fs, OutFile: out, FollowLink: true}, `root
 └── symlink -> root/symlink [recursive, not followed]
+`, 0, 1},
+	{"symlink-classify", &Options{Fs: fs, OutFile: out, Classify: true}, `root
+└── symlink@ -> root/symlink
 `, 0, 1},
 }
 
@@ -656,3 +714,53 @@ func TestError(t *testing.T) {
 		out.clear()
 	}
 }
+
+// Mock filesystem cancelling a context when a directory is read
+type cancellingFs struct {
+	*MockFs
+	at     string
+	cancel context.CancelFunc
+}
+
+func (fs *cancellingFs) ReadDir(path string) ([]string, error) {
+	if path == fs.at {
+		fs.cancel()
+	}
+	return fs.MockFs.ReadDir(path)
+}
+
+func TestVisitContext(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", files: []*file{
+				{name: "b", files: []*file{{name: "c"}, {name: "d"}}},
+				{name: "e"},
+			}},
+			{name: "f"},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cfs := &cancellingFs{MockFs: NewFs().addFile(root.name, root), at: "root/a/b", cancel: cancel}
+	opts := &Options{Fs: cfs, OutFile: out}
+	defer out.clear()
+	inf := New(root.name)
+	d, f, err := inf.VisitContext(ctx, opts)
+	if err != context.Canceled {
+		t.Errorf("expected the visit to be cancelled, got %v", err)
+	}
+	if d != 2 || f != 0 {
+		t.Errorf("expect (dir, file) count to be equal to (2, 0), got (%d, %d)", d, f)
+	}
+	inf.Print(opts)
+	expected := `root [incomplete]
+└── a [incomplete]
+    └── b [incomplete]
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%s\nexpected:\n%s", out.str, expected)
+	}
+	if _, _, err := New(root.name).VisitContext(context.Background(), opts); err != nil {
+		t.Errorf("expected the visit to complete, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package ostree
 
 import (
+	"io"
 	"os"
 )
 
@@ -25,3 +26,17 @@ func (f *FS) ReadDir(path string) ([]string, error) {
 	}
 	return names, nil
 }
+
+// Readlink returns the target of a link
+func (f *FS) Readlink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+// Open a file for reading
+func (f *FS) Open(path string) (io.ReadSeekCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,116 @@
+//go:build linux
+// +build linux
+
+package ostree
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF
+
+// Watcher reports changes to the entries of directories, using inotify.
+type Watcher struct {
+	file    *os.File
+	events  chan string
+	done    chan struct{}
+	mu      sync.Mutex
+	watches map[int32]string
+	dirs    map[string]bool
+}
+
+// NewWatcher returns a Watcher of the system filesystem.
+func (f *FS) NewWatcher() (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &Watcher{
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		events:  make(chan string, 64),
+		done:    make(chan struct{}),
+		watches: make(map[int32]string),
+		dirs:    make(map[string]bool),
+	}
+	go w.read()
+	return w, nil
+}
+
+// Watch a directory, watching it again is harmless.
+func (w *Watcher) Watch(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dirs[dir] {
+		return nil
+	}
+	conn, err := w.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var wd int
+	cerr := conn.Control(func(fd uintptr) {
+		wd, err = syscall.InotifyAddWatch(int(fd), dir, watchMask)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	w.watches[int32(wd)] = dir
+	w.dirs[dir] = true
+	return nil
+}
+
+// Events returns the channel receiving the paths of changed entries.
+func (w *Watcher) Events() <-chan string {
+	return w.events
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.file.Close()
+}
+
+func (w *Watcher) read() {
+	defer close(w.events)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			w.mu.Lock()
+			dir, ok := w.watches[ev.Wd]
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.watches, ev.Wd)
+				delete(w.dirs, dir)
+			}
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+			path := dir
+			if name = bytes.TrimRight(name, "\x00"); len(name) > 0 {
+				path = filepath.Join(dir, string(name))
+			}
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package ostree
+
+import "errors"
+
+// Watcher reports changes to the entries of directories, using inotify.
+type Watcher struct{}
+
+// NewWatcher returns a Watcher of the system filesystem.
+func (f *FS) NewWatcher() (*Watcher, error) {
+	return nil, errors.New("watching is only supported on linux")
+}
+
+// Watch a directory, watching it again is harmless.
+func (w *Watcher) Watch(dir string) error { return nil }
+
+// Events returns the channel receiving the paths of changed entries.
+func (w *Watcher) Events() <-chan string { return nil }
+
+// Close stops watching.
+func (w *Watcher) Close() error { return nil }
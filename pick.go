@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Keys read by the picker, as sent by terminals in raw mode.
+const (
+	keyUp        = "\x1b[A"
+	keyDown      = "\x1b[B"
+	keyRight     = "\x1b[C"
+	keyLeft      = "\x1b[D"
+	keyPageUp    = "\x1b[5~"
+	keyPageDown  = "\x1b[6~"
+	keyEsc       = "\x1b"
+	keyEnter     = "\r"
+	keyBackspace = "\x7f"
+	keyCtrlC     = "\x03"
+)
+
+// Picker is an interactive browser of a tree, whose directories are read
+// when they are first expanded.
+type Picker struct {
+	opts     *Options
+	root     *Node
+	expanded map[*Node]bool
+	// Height is the number of rows shown, including the status line
+	Height int
+
+	rows      []pickerRow
+	cursor    int
+	offset    int
+	filter    string
+	filtering bool
+}
+
+type pickerRow struct {
+	node   *Node
+	prefix string
+}
+
+// NewPicker returns a Picker of the tree rooted at dir.
+func NewPicker(dir string, opts *Options) *Picker {
+	popts := *opts
+	// pruning would need the whole tree
+	popts.Prune = false
+	p := &Picker{
+		opts:     &popts,
+		root:     New(dir),
+		expanded: make(map[*Node]bool),
+		Height:   24,
+	}
+	p.expand(p.root)
+	p.layout()
+	return p
+}
+
+// expand a directory, visiting its children the first time.
+func (p *Picker) expand(node *Node) {
+	if node.FileInfo != nil && !node.IsDir() {
+		return
+	}
+	if node.nodes == nil {
+		if p.opts.DeepLevel > 0 && node.depth >= p.opts.DeepLevel {
+			return
+		}
+		opts := *p.opts
+		opts.DeepLevel = node.depth + 1
+		node.Visit(&opts)
+	}
+	p.expanded[node] = true
+}
+
+// Selected returns the node under the cursor.
+func (p *Picker) Selected() *Node {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	return p.rows[p.cursor].node
+}
+
+// matches tells whether the node, or one of its visited descendants,
+// matches the filter.
+func (p *Picker) matches(node *Node) bool {
+	if strings.Contains(strings.ToLower(baseName(node)), strings.ToLower(p.filter)) {
+		return true
+	}
+	for _, nnode := range node.nodes {
+		if nnode.FileInfo != nil && p.matches(nnode) {
+			return true
+		}
+	}
+	return false
+}
+
+// layout computes the visible rows.
+func (p *Picker) layout() {
+	selected := p.Selected()
+	p.rows = []pickerRow{{node: p.root}}
+	if p.expanded[p.root] || p.filter != "" {
+		p.layoutChildren(p.root, "")
+	}
+	p.cursor = 0
+	for i, row := range p.rows {
+		if row.node == selected {
+			p.cursor = i
+		}
+	}
+}
+
+func (p *Picker) layoutChildren(node *Node, indent string) {
+	var nodes Nodes
+	for _, nnode := range node.nodes {
+		if p.filter == "" || nnode.FileInfo == nil || p.matches(nnode) {
+			nodes = append(nodes, nnode)
+		}
+	}
+	for i, nnode := range nodes {
+		branch, add := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, add = "└── ", "    "
+		}
+		if p.opts.NoIndent {
+			branch, add = "", ""
+		}
+		p.rows = append(p.rows, pickerRow{node: nnode, prefix: indent + branch})
+		if p.expanded[nnode] || (p.filter != "" && len(nnode.nodes) > 0) {
+			p.layoutChildren(nnode, indent+add)
+		}
+	}
+}
+
+// HandleKey updates the picker for a key read from the terminal. It returns
+// done once the user is done, with the picked path unless they gave up.
+func (p *Picker) HandleKey(key string) (path string, done bool) {
+	if p.filtering {
+		switch key {
+		case keyEnter:
+			p.filtering = false
+		case keyEsc:
+			p.filtering, p.filter = false, ""
+		case keyBackspace:
+			_, size := utf8.DecodeLastRuneInString(p.filter)
+			p.filter = p.filter[:len(p.filter)-size]
+		case keyUp, keyDown, keyPageUp, keyPageDown:
+			p.move(key)
+		default:
+			if key >= " " && !strings.HasPrefix(key, keyEsc) {
+				p.filter += key
+			}
+		}
+		p.layout()
+		return "", false
+	}
+	node := p.Selected()
+	switch key {
+	case "q", keyEsc, keyCtrlC:
+		return "", true
+	case "p":
+		return node.path, true
+	case "/":
+		p.filtering = true
+	case keyEnter, " ":
+		if node.FileInfo != nil && !node.IsDir() {
+			if key == keyEnter {
+				return node.path, true
+			}
+		} else if p.expanded[node] {
+			delete(p.expanded, node)
+		} else {
+			p.expand(node)
+		}
+	case keyRight, "l":
+		p.expand(node)
+	case keyLeft, "h":
+		if p.expanded[node] && node != p.root {
+			delete(p.expanded, node)
+		} else {
+			// go to the parent
+			for i := p.cursor - 1; i >= 0; i-- {
+				if p.rows[i].node.depth < node.depth {
+					p.cursor = i
+					break
+				}
+			}
+		}
+	case "j":
+		p.move(keyDown)
+	case "k":
+		p.move(keyUp)
+	default:
+		p.move(key)
+	}
+	p.layout()
+	return "", false
+}
+
+func (p *Picker) move(key string) {
+	page := p.Height - 1
+	switch key {
+	case keyUp:
+		p.cursor--
+	case keyDown:
+		p.cursor++
+	case keyPageUp:
+		p.cursor -= page
+	case keyPageDown:
+		p.cursor += page
+	}
+	if p.cursor >= len(p.rows) {
+		p.cursor = len(p.rows) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// Draw the visible rows and the status line.
+func (p *Picker) Draw(w io.Writer) {
+	lines := p.Height - 1
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	} else if p.cursor >= p.offset+lines {
+		p.offset = p.cursor - lines + 1
+	}
+	var b bytes.Buffer
+	b.WriteString(Escape + "[H" + Escape + "[2J")
+	for i := p.offset; i < len(p.rows) && i < p.offset+lines; i++ {
+		row := p.rows[i]
+		name := row.node.path
+		if row.node.depth != 0 {
+			name = baseName(row.node)
+		}
+		if row.node.FileInfo != nil && row.node.IsDir() {
+			name += "/"
+		}
+		if i == p.cursor {
+			name = ANSIColorFormat("7", name)
+		} else if p.opts.Colorize && row.node.FileInfo != nil {
+			name = p.opts.color(row.node, name)
+		}
+		fmt.Fprintf(&b, "%s%s\r\n", row.prefix, name)
+	}
+	if p.filtering || p.filter != "" {
+		fmt.Fprintf(&b, "/%s", p.filter)
+	} else {
+		b.WriteString("↑↓ move  ⏎ expand  / filter  p pick  q quit")
+	}
+	w.Write(b.Bytes())
+}
+
+// runPicker browses dir on the terminal, and returns the picked path.
+func runPicker(dir string, opts *Options) (string, bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false, err
+	}
+	defer tty.Close()
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return "", false, err
+	}
+	defer term.Restore(int(tty.Fd()), state)
+	// alternate screen, hidden cursor
+	fmt.Fprint(tty, Escape+"[?1049h"+Escape+"[?25l")
+	defer fmt.Fprint(tty, Escape+"[?25h"+Escape+"[?1049l")
+
+	p := NewPicker(dir, opts)
+	buf := make([]byte, 32)
+	for {
+		if _, height, err := term.GetSize(int(tty.Fd())); err == nil && height > 1 {
+			p.Height = height
+		}
+		p.Draw(tty)
+		n, err := tty.Read(buf)
+		if err != nil {
+			return "", false, err
+		}
+		if path, done := p.HandleKey(string(buf[:n])); done {
+			return path, path != "", nil
+		}
+	}
+}
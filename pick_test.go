@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Mock filesystem counting the directories read
+type countingFs struct {
+	*MockFs
+	reads []string
+}
+
+func (fs *countingFs) ReadDir(path string) ([]string, error) {
+	fs.reads = append(fs.reads, path)
+	return fs.MockFs.ReadDir(path)
+}
+
+func pickerScreen(p *Picker) string {
+	var lines []string
+	for i, row := range p.rows {
+		line := row.prefix + baseName(row.node)
+		if i == p.cursor {
+			line += " <"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPicker(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "b", files: []*file{
+				{name: "c", files: []*file{{name: "d"}}},
+				{name: "e"},
+			}},
+		},
+	}
+	cfs := &countingFs{MockFs: NewFs().addFile(root.name, root)}
+	p := NewPicker(root.name, &Options{Fs: cfs})
+	if strings.Join(cfs.reads, ",") != "root" {
+		t.Errorf("expected only root to be read, got %v", cfs.reads)
+	}
+
+	steps := []struct {
+		keys     []string
+		expected string
+	}{
+		{nil, "root <\n├── a\n└── b"},
+		{[]string{keyDown, keyDown, keyEnter}, "root\n├── a\n└── b <\n    ├── c\n    └── e"},
+		{[]string{"j", keyRight}, "root\n├── a\n└── b\n    ├── c <\n    │   └── d\n    └── e"},
+		{[]string{"/", "d"}, "root\n└── b\n    └── c <\n        └── d"},
+		{[]string{keyEsc, keyLeft}, "root\n├── a\n└── b\n    ├── c <\n    └── e"},
+		{[]string{keyLeft}, "root\n├── a\n└── b <\n    ├── c\n    └── e"},
+	}
+	for _, step := range steps {
+		for _, key := range step.keys {
+			if _, done := p.HandleKey(key); done {
+				t.Fatalf("picker done after %q", key)
+			}
+		}
+		if actual := pickerScreen(p); actual != step.expected {
+			t.Errorf("after %q:\ngot:\n%s\nexpected:\n%s", step.keys, actual, step.expected)
+		}
+	}
+	if strings.Join(cfs.reads, ",") != "root,root/b,root/b/c" {
+		t.Errorf("expected directories to be read once when expanded, got %v", cfs.reads)
+	}
+	if path, done := p.HandleKey("p"); !done || path != "root/b" {
+		t.Errorf("expected root/b to be picked, got %q", path)
+	}
+}
+
+func TestPickerFilterBackspace(t *testing.T) {
+	root := &file{name: "root", files: []*file{{name: "a"}}}
+	p := NewPicker(root.name, &Options{Fs: NewFs().addFile(root.name, root)})
+	for _, key := range []string{"/", "é", "ü", keyBackspace} {
+		p.HandleKey(key)
+	}
+	if p.filter != "é" {
+		t.Errorf("expected the filter to be %q, got %q", "é", p.filter)
+	}
+	for _, key := range []string{keyBackspace, keyBackspace} {
+		p.HandleKey(key)
+	}
+	if p.filter != "" {
+		t.Errorf("expected an empty filter, got %q", p.filter)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Renderer formats trees, as driven by the Walker of RenderWalker.
+type Renderer interface {
+	// BeginDir is called on a directory, or a followed link to one,
+	// before its contents.
+	BeginDir(node *Node) error
+	// EndDir is called on a directory after its contents.
+	EndDir(node *Node) error
+	// Entry is called on the other nodes.
+	Entry(node *Node) error
+	// Error is called on the nodes whose Err is set: instead of the other
+	// functions for the nodes that could not be stat'ed, and after
+	// BeginDir for the directories that could not be read.
+	Error(node *Node, err error) error
+	// Report is called once the trees are rendered, unless the report is
+	// turned off.
+	Report(report *Report) error
+}
+
+// FlushRenderer is a Renderer writing its output once all the trees are
+// rendered, such as the formats making a single document of them.
+type FlushRenderer interface {
+	Renderer
+	// Flush is called last, after Report.
+	Flush() error
+}
+
+var renderers = map[string]func(opts *Options) Renderer{
+	"text": func(opts *Options) Renderer { return NewTextRenderer(opts) },
+}
+
+// RegisterRenderer makes a renderer available by name, such as to the
+// --renderer option.
+func RegisterRenderer(name string, newRenderer func(opts *Options) Renderer) {
+	renderers[name] = newRenderer
+}
+
+// RendererNames returns the names of the registered renderers.
+func RendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewRenderer returns the renderer registered under name.
+func NewRenderer(name string, opts *Options) (Renderer, error) {
+	newRenderer, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("renderer '%s' not valid, should be one of: %s",
+			name, strings.Join(RendererNames(), ","))
+	}
+	return newRenderer(opts), nil
+}
+
+// RenderWalker returns the Walker calling the functions of r, for Walk to
+// render trees as they are visited: Pre begins the directories, and the
+// links followed to one, or renders the other nodes as entries, and Post
+// ends the directories.
+func RenderWalker(r Renderer) Walker {
+	// dirs tells, for each node passed to Pre, whether it was begun
+	var dirs []bool
+	return Walker{
+		Pre: func(node *Node) error {
+			isDir := node.IsDir() || len(node.nodes) > 0
+			dirs = append(dirs, isDir)
+			if isDir {
+				return r.BeginDir(node)
+			}
+			return r.Entry(node)
+		},
+		Post: func(node *Node) error {
+			isDir := dirs[len(dirs)-1]
+			dirs = dirs[:len(dirs)-1]
+			if isDir {
+				return r.EndDir(node)
+			}
+			return nil
+		},
+		Error: r.Error,
+	}
+}
+
+// streamable tells whether r can render the trees as they are walked,
+// rather than once visited whole: not when sorting by number of entries,
+// nor when showing the sizes of directories, which add up their contents.
+// The renderers collecting the trees have them whole when writing them.
+func (opts *Options) streamable(r Renderer) bool {
+	for _, key := range opts.sortKeys() {
+		if key.Name == "count" {
+			return false
+		}
+	}
+	var columns []Column
+	switch r := r.(type) {
+	case *tableRenderer:
+		columns = r.columns
+	case FlushRenderer:
+		return true
+	default:
+		columns = opts.textColumns()
+	}
+	for _, c := range columns {
+		if c.Name() == "size" {
+			return false
+		}
+	}
+	return true
+}
+
+// Render calls the functions of r on the visited tree of node, through
+// RenderWalker, keeping its contents.
+func Render(opts *Options, node *Node, r Renderer) error {
+	err := node.walk(context.Background(), opts, RenderWalker(r), false, false)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		err = nil
+	}
+	return err
+}
+
+// Finish ends the rendering by r, with the report unless it is nil.
+func Finish(r Renderer, report *Report) error {
+	if report != nil {
+		if err := r.Report(report); err != nil {
+			return err
+		}
+	}
+	if fr, ok := r.(FlushRenderer); ok {
+		return fr.Flush()
+	}
+	return nil
+}
+
+// treeCollector is the part of a Renderer collecting the trees rendered,
+// for the formats written once they are whole. The nodes are copied, as
+// Walk forgets their contents.
+type treeCollector struct {
+	trees Nodes
+	dirs  Nodes
+}
+
+func (c *treeCollector) add(node *Node) *Node {
+	n := *node
+	n.nodes = nil
+	if len(c.dirs) == 0 {
+		c.trees = append(c.trees, &n)
+	} else {
+		parent := c.dirs[len(c.dirs)-1]
+		parent.nodes = append(parent.nodes, &n)
+	}
+	return &n
+}
+
+func (c *treeCollector) BeginDir(node *Node) error {
+	c.dirs = append(c.dirs, c.add(node))
+	return nil
+}
+
+func (c *treeCollector) EndDir(node *Node) error {
+	c.dirs = c.dirs[:len(c.dirs)-1]
+	return nil
+}
+
+func (c *treeCollector) Entry(node *Node) error {
+	c.add(node)
+	return nil
+}
+
+func (c *treeCollector) Error(node *Node, err error) error {
+	if node.FileInfo == nil {
+		c.add(node)
+	}
+	return nil
+}
+
+// follow visits the target of a link to a directory with the FollowLink
+// option, unless it is being visited already, and tells whether it did.
+func (node *Node) follow(opts *Options) bool {
+	if !opts.FollowLink || node.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	if len(node.nodes) > 0 {
+		// followed already, as when rendered again
+		return true
+	}
+	vtarget, err := os.Readlink(node.path)
+	if err != nil {
+		vtarget = node.path
+	}
+	targetPath, err := filepath.EvalSymlinks(node.path)
+	if err != nil {
+		targetPath = vtarget
+	}
+	fi, err := opts.Fs.Stat(targetPath)
+	if err != nil || !fi.IsDir() {
+		return false
+	}
+	path, err := filepath.Abs(targetPath)
+	if err != nil {
+		return false
+	}
+	if _, ok := node.vpaths[filepath.Clean(path)]; ok {
+		node.recursive = true
+		return false
+	}
+	inf := &Node{FileInfo: fi, path: targetPath}
+	inf.vpaths = node.vpaths
+	inf.Visit(opts)
+	node.nodes = inf.nodes
+	return true
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Renderer recording its calls
+type recordingRenderer struct {
+	calls []string
+	// skip is the directory whose contents are skipped
+	skip string
+}
+
+func (r *recordingRenderer) BeginDir(node *Node) error {
+	r.calls = append(r.calls, "begin "+node.path)
+	if node.path == r.skip {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+func (r *recordingRenderer) EndDir(node *Node) error {
+	r.calls = append(r.calls, "end "+node.path)
+	return nil
+}
+
+func (r *recordingRenderer) Entry(node *Node) error {
+	r.calls = append(r.calls, "entry "+node.path)
+	return nil
+}
+
+func (r *recordingRenderer) Error(node *Node, err error) error {
+	r.calls = append(r.calls, "error "+node.path+": "+errMessage(err))
+	return nil
+}
+
+func (r *recordingRenderer) Report(report *Report) error {
+	r.calls = append(r.calls, fmt.Sprintf("report %d %d", report.Directories, report.Files))
+	return nil
+}
+
+func TestRender(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "bad"},
+			{name: "b", files: []*file{{name: "c"}}},
+			{name: "locked", files: []*file{{name: "x"}}},
+		},
+	}
+	fs := &unreadableFs{NewFs().addFile(root.name, root), map[string]bool{"root/locked": true}}
+	opts := &Options{Fs: fs}
+	RegisterRenderer("recording", func(*Options) Renderer { return new(recordingRenderer) })
+	defer delete(renderers, "recording")
+	if names := strings.Join(RendererNames(), ","); names != "graph,json,markdown,recording,table,text,xml" {
+		t.Errorf("wrong renderer names: %s", names)
+	}
+	r, err := NewRenderer("recording", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inf := New(root.name)
+	d, f := inf.Visit(opts)
+	if err := Render(opts, inf, r); err != nil {
+		t.Fatal(err)
+	}
+	r.Report(NewReport(d, f))
+	expected := []string{
+		"begin root",
+		"entry root/a",
+		"begin root/b",
+		"entry root/b/c",
+		"end root/b",
+		"begin root/locked",
+		"error root/locked: permission denied",
+		"end root/locked",
+		"error root/bad: stat failed",
+		"end root",
+		"report 2 2",
+	}
+	if actual := strings.Join(r.(*recordingRenderer).calls, "\n"); actual != strings.Join(expected, "\n") {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, strings.Join(expected, "\n"))
+	}
+	if _, err := NewRenderer("nope", opts); err == nil {
+		t.Error("expected renderer nope to be rejected")
+	}
+
+	// the same calls as the tree is walked
+	w := new(recordingRenderer)
+	if err := Walk(context.Background(), root.name, opts, RenderWalker(w)); err != nil {
+		t.Fatal(err)
+	}
+	w.Report(NewReport(d, f))
+	if actual := strings.Join(w.calls, "\n"); actual != strings.Join(expected, "\n") {
+		t.Errorf("walked:\ngot:\n%s\nexpected:\n%s", actual, strings.Join(expected, "\n"))
+	}
+
+	// skipped directories are ended all the same
+	s := &recordingRenderer{skip: "root/b"}
+	if err := Walk(context.Background(), root.name, opts, RenderWalker(s)); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{
+		"begin root",
+		"entry root/a",
+		"begin root/b",
+		"end root/b",
+		"begin root/locked",
+		"error root/locked: permission denied",
+		"end root/locked",
+		"error root/bad: stat failed",
+		"end root",
+	}
+	if actual := strings.Join(s.calls, "\n"); actual != strings.Join(expected, "\n") {
+		t.Errorf("skipped:\ngot:\n%s\nexpected:\n%s", actual, strings.Join(expected, "\n"))
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 5},
+			{name: "b", files: []*file{
+				{name: "c", files: []*file{{name: "d", size: 7}}},
+				{name: "e", size: 3},
+			}},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	report := NewReport(2, 3)
+	columns, _ := ParseColumns("path,type")
+	tests := []struct {
+		renderer string
+		opts     Options
+		print    func(opts *Options, trees Nodes) error
+	}{
+		{"json", Options{}, func(opts *Options, trees Nodes) error { return PrintJSON(opts, trees, report) }},
+		{"xml", Options{}, func(opts *Options, trees Nodes) error { return PrintXML(opts, trees, report) }},
+		{"markdown", Options{}, func(opts *Options, trees Nodes) error {
+			return PrintMarkdown(opts, "code", trees, report.footer(opts))
+		}},
+		{"markdown", Options{Markdown: "list"}, func(opts *Options, trees Nodes) error {
+			return PrintMarkdown(opts, "list", trees, report.footer(opts))
+		}},
+		{"graph", Options{ByteSize: true}, func(opts *Options, trees Nodes) error {
+			return PrintGraph(opts, GraphOptions{Format: "dot"}, trees)
+		}},
+		{"graph", Options{Graph: GraphOptions{Format: "mermaid", Count: true}}, func(opts *Options, trees Nodes) error {
+			return PrintGraph(opts, opts.Graph, trees)
+		}},
+		{"table", Options{}, func(opts *Options, trees Nodes) error {
+			columns, _ := ParseColumns("path,type,size")
+			return PrintTable(opts, "csv", columns, true, trees)
+		}},
+		{"table", Options{Table: "tsv", NoHeader: true, Columns: columns}, func(opts *Options, trees Nodes) error {
+			return PrintTable(opts, "tsv", columns, false, trees)
+		}},
+	}
+	for _, test := range tests {
+		var expected, actual bytes.Buffer
+		opts := test.opts
+		opts.Fs, opts.OutFile = fs, &expected
+		tr := New("root")
+		tr.Visit(&opts)
+		if err := test.print(&opts, Nodes{tr}); err != nil {
+			t.Fatal(err)
+		}
+
+		opts.OutFile = &actual
+		r, err := NewRenderer(test.renderer, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opts.streamable(r) {
+			err = Walk(context.Background(), "root", &opts, RenderWalker(r))
+		} else {
+			err = Render(&opts, tr, r)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Finish(r, report); err != nil {
+			t.Fatal(err)
+		}
+		if actual.String() != expected.String() {
+			t.Errorf("%s: got:\n%s\nexpected:\n%s", test.renderer, actual.String(), expected.String())
+		}
+	}
+}
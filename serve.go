@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FileOpener is implemented by filesystems whose files can be read, such as
+// ostree.FS.
+type FileOpener interface {
+	Open(path string) (io.ReadSeekCloser, error)
+}
+
+// Server serves a read-only view of the tree rooted at Root:
+//
+//	/                   the tree as a page, reading directories when expanded
+//	/api/tree?path=p    the Entry of directory p, with its children
+//	/api/tree?P=x&I=y   the whole tree, further pruned to the files matching -P x / -I y
+//	/files/p            the content of file p, when Fs is a FileOpener
+type Server struct {
+	Root string
+	Opts *Options
+}
+
+// NewServer returns a Server of the tree rooted at root.
+func NewServer(root string, opts *Options) *Server {
+	return &Server{Root: root, Opts: opts}
+}
+
+// ListenAndServe serves the tree on the TCP address addr, with timeouts
+// leaving slow clients out.
+func (s *Server) ListenAndServe(addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		// files are sent whole
+		WriteTimeout: 10 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+	return server.ListenAndServe()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method != http.MethodGet && r.Method != http.MethodHead:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	case r.URL.Path == "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		serveTemplate.Execute(w, s.Root)
+	case r.URL.Path == "/api/tree":
+		s.serveTree(w, r)
+	case strings.HasPrefix(r.URL.Path, "/files/"):
+		s.serveFile(w, r, strings.TrimPrefix(r.URL.Path, "/files/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var errForbidden = errors.New("forbidden path")
+
+// resolve returns the path of rel under the root, refusing paths escaping
+// it and those the server would not list: hidden, deeper than -L, on
+// another device with -x, or left out by -P, -I and -d.
+func (s *Server) resolve(rel string) (string, string, error) {
+	rel = path.Clean("/" + rel)[1:]
+	if rel == "" {
+		return rel, s.Root, nil
+	}
+	opts := s.Opts
+	names := strings.Split(rel, "/")
+	if opts.DeepLevel > 0 && len(names) > opts.DeepLevel {
+		return "", "", errForbidden
+	}
+	device := s.device()
+	dir := s.Root
+	var dirMatch bool
+	for i, name := range names {
+		if !opts.All && strings.HasPrefix(name, ".") {
+			return "", "", errForbidden
+		}
+		dir = filepath.Join(dir, name)
+		fi, err := opts.Fs.Stat(dir)
+		if err != nil {
+			// left to the handlers, which answer not found
+			return rel, dir, nil
+		}
+		if ok, _, d, _, _ := getStat(fi); ok && device != 0 && d != device {
+			return "", "", errForbidden
+		}
+		// links are only followed with -l, as they may lead out of the tree
+		if i < len(names)-1 && !opts.FollowLink && fi.Mode()&os.ModeSymlink != 0 {
+			return "", "", errForbidden
+		}
+		node := &Node{path: dir, depth: i + 1, FileInfo: fi}
+		if !fi.IsDir() {
+			if opts.DirsOnly ||
+				!dirMatch && opts.Pattern != "" && !node.match(opts.Pattern, opts) ||
+				opts.IPattern != "" && node.match(opts.IPattern, opts) {
+				return "", "", errForbidden
+			}
+			continue
+		}
+		if opts.MatchDirs {
+			if opts.IPattern != "" && node.match(opts.IPattern, opts) {
+				return "", "", errForbidden
+			}
+			dirMatch = opts.Pattern != "" && node.match(opts.Pattern, opts)
+		}
+	}
+	return rel, dir, nil
+}
+
+// device returns the device of the root with -x, 0 otherwise.
+func (s *Server) device() uint64 {
+	if !s.Opts.OneFS {
+		return 0
+	}
+	fi, err := s.Opts.Fs.Stat(s.Root)
+	if err != nil {
+		return 0
+	}
+	_, _, device, _, _ := getStat(fi)
+	return device
+}
+
+func (s *Server) serveTree(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := *s.Opts
+	// the page builds paths out of names
+	opts.FullPath = false
+	node := New(s.Root)
+	pattern, ipattern := query.Get("P"), query.Get("I")
+	if pattern != "" || ipattern != "" {
+		// search the whole tree
+		for _, p := range []string{pattern, ipattern} {
+			if _, err := regexp.Compile(p); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		// on top of the patterns of the server, which are never lifted: the
+		// query ones are only passed on when the server has none
+		if opts.Pattern == "" {
+			opts.Pattern, pattern = pattern, ""
+		}
+		if opts.IPattern == "" {
+			opts.IPattern, ipattern = ipattern, ""
+		}
+		opts.Prune = true
+	} else {
+		// read a single directory
+		rel, dir, err := s.resolve(query.Get("path"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		node = New(dir)
+		if rel != "" {
+			node.depth = strings.Count(rel, "/") + 1
+			node.device = s.device()
+		}
+		if opts.DeepLevel == 0 || opts.DeepLevel > node.depth+1 {
+			opts.DeepLevel = node.depth + 1
+		}
+	}
+	node.Visit(&opts)
+	if pattern != "" || ipattern != "" {
+		filterTree(node, pattern, ipattern, &opts)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(node.Entry(&opts))
+}
+
+// filterTree leaves out of the visited tree the files not matching pattern
+// or matching ipattern, when set, and the directories left empty. It
+// returns the number of files left.
+func filterTree(node *Node, pattern, ipattern string, opts *Options) (files int) {
+	nodes := node.nodes[:0]
+	for _, nnode := range node.nodes {
+		if nnode.FileInfo != nil && nnode.IsDir() {
+			f := filterTree(nnode, pattern, ipattern, opts)
+			if f == 0 {
+				continue
+			}
+			files += f
+		} else if nnode.FileInfo != nil {
+			if pattern != "" && !nnode.match(pattern, opts) ||
+				ipattern != "" && nnode.match(ipattern, opts) {
+				continue
+			}
+			files++
+		}
+		nodes = append(nodes, nnode)
+	}
+	node.nodes = nodes
+	return
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, rel string) {
+	fs, ok := s.Opts.Fs.(FileOpener)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, name, err := s.resolve(rel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	fi, err := s.Opts.Fs.Stat(name)
+	if err != nil || !fi.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := fs.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Disposition", "attachment")
+	http.ServeContent(w, r, fi.Name(), time.Time{}, file)
+}
+
+var serveTemplate = template.Must(template.New("tree").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body { font-family: monospace; }
+ul { list-style: none; padding-left: 1.5em; margin: 0; }
+summary { cursor: pointer; }
+.file { padding-left: 1.1em; }
+.meta { color: #888; }
+</style>
+</head>
+<body>
+<form id="search">
+<input name="P" placeholder="-P pattern"> <input name="I" placeholder="-I pattern">
+<button>search</button> <button type="reset">clear</button>
+</form>
+<h3>{{.}}</h3>
+<ul id="root"></ul>
+<script>
+function meta(e) {
+  const span = document.createElement("span");
+  const m = [e.prot, e.user, e.group, e.size, e.time].filter(x => x !== undefined);
+  if (m.length) span.append(" ", Object.assign(document.createElement("span"), {className: "meta", textContent: "[" + m.join(" ") + "]"}));
+  if (e.error) span.append(" [" + e.error + "]");
+  return span;
+}
+function item(e, path, open) {
+  const li = document.createElement("li");
+  const name = document.createTextNode(e.name);
+  if (e.type === "directory") {
+    const details = document.createElement("details");
+    const summary = document.createElement("summary");
+    const ul = document.createElement("ul");
+    summary.append(name, meta(e));
+    details.append(summary, ul);
+    if (e.contents) {
+      render(ul, e.contents, path, open);
+      details.open = open;
+    } else {
+      details.addEventListener("toggle", () => {
+        if (details.open && !ul.dataset.loaded) {
+          ul.dataset.loaded = true;
+          load(ul, path);
+        }
+      });
+    }
+    li.appendChild(details);
+  } else {
+    const a = document.createElement("a");
+    a.className = "file";
+    a.href = "files/" + path.split("/").map(encodeURIComponent).join("/");
+    a.appendChild(name);
+    li.append(a, meta(e));
+  }
+  return li;
+}
+function render(ul, entries, parent, open) {
+  ul.replaceChildren(...(entries || []).map(e => item(e, parent ? parent + "/" + e.name : e.name, open)));
+}
+async function fetchTree(params) {
+  const res = await fetch("api/tree?" + new URLSearchParams(params));
+  if (!res.ok) throw new Error(await res.text());
+  return res.json();
+}
+async function load(ul, path) {
+  const e = await fetchTree({path});
+  render(ul, e.contents, path, false);
+}
+document.getElementById("search").addEventListener("submit", async ev => {
+  ev.preventDefault();
+  const form = new FormData(ev.target);
+  const ul = document.getElementById("root");
+  if (!form.get("P") && !form.get("I")) return load(ul, "");
+  try {
+    const e = await fetchTree(form);
+    render(ul, e.contents, "", true);
+  } catch (err) {
+    ul.textContent = err.message;
+  }
+});
+document.getElementById("search").addEventListener("reset", () => load(document.getElementById("root"), ""));
+load(document.getElementById("root"), "");
+</script>
+</body>
+</html>
+`))
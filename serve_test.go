@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Mock filesystem whose files hold their own path
+type openerFs struct {
+	*countingFs
+}
+
+type contentFile struct {
+	*strings.Reader
+}
+
+func (contentFile) Close() error { return nil }
+
+func (fs openerFs) Open(path string) (io.ReadSeekCloser, error) {
+	return contentFile{strings.NewReader(path)}, nil
+}
+
+func TestServer(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: ".hidden", files: []*file{{name: "x"}}},
+			{name: "a", size: 4},
+			{name: "b", mode: os.ModeDir | 0755, files: []*file{
+				{name: "c", files: []*file{{name: "d"}}},
+				{name: "e"},
+			}},
+		},
+	}
+	cfs := &countingFs{MockFs: NewFs().addFile(root.name, root)}
+	s := NewServer(root.name, &Options{Fs: openerFs{cfs}})
+
+	tests := []struct {
+		url      string
+		code     int
+		expected string
+	}{
+		{"/api/tree", 200, `{"type":"directory","name":"root","contents":[{"type":"file","name":"a"},{"type":"directory","name":"b"}]}`},
+		{"/api/tree?path=b", 200, `{"type":"directory","name":"b","contents":[{"type":"directory","name":"c"},{"type":"file","name":"e"}]}`},
+		{"/api/tree?path=b/c", 200, `{"type":"directory","name":"c","contents":[{"type":"file","name":"d"}]}`},
+		{"/api/tree?P=d", 200, `{"type":"directory","name":"root","contents":[{"type":"directory","name":"b","contents":[{"type":"directory","name":"c","contents":[{"type":"file","name":"d"}]}]}]}`},
+		{"/api/tree?P=[", 400, ""},
+		{"/api/tree?path=.hidden", 403, ""},
+		{"/api/tree?path=../../b/../..", 200, `{"type":"directory","name":"root","contents":[{"type":"file","name":"a"},{"type":"directory","name":"b"}]}`},
+		{"/files/b/c/d", 200, "root/b/c/d"},
+		{"/files/b", 404, ""},
+		{"/files/.hidden/x", 403, ""},
+		{"/files/../a", 200, "root/a"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if rec.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.url, test.code, rec.Code)
+			continue
+		}
+		if actual := strings.TrimSpace(rec.Body.String()); test.expected != "" && actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.url, actual, test.expected)
+		}
+	}
+
+	cfs.reads = nil
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tree?path=b", nil))
+	if strings.Join(cfs.reads, ",") != "root/b" {
+		t.Errorf("expected only root/b to be read, got %v", cfs.reads)
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", files: []*file{{name: "x"}, {name: "y"}, {name: "z"}}},
+			{name: "b", files: []*file{{name: "c", files: []*file{{name: "y"}}}}},
+		},
+	}
+	cfs := &countingFs{MockFs: NewFs().addFile(root.name, root)}
+	s := NewServer(root.name, &Options{Fs: openerFs{cfs}, IPattern: "x", DeepLevel: 2})
+
+	tests := []struct {
+		url      string
+		code     int
+		expected string
+	}{
+		{"/api/tree?path=a", 200, `{"type":"directory","name":"a","contents":[{"type":"file","name":"y"},{"type":"file","name":"z"}]}`},
+		{"/api/tree?P=x", 200, `{"type":"directory","name":"root"}`},
+		{"/api/tree?P=y", 200, `{"type":"directory","name":"root","contents":[{"type":"directory","name":"a","contents":[{"type":"file","name":"y"}]}]}`},
+		{"/api/tree?I=y", 200, `{"type":"directory","name":"root","contents":[{"type":"directory","name":"a","contents":[{"type":"file","name":"z"}]}]}`},
+		{"/api/tree?path=b/c", 200, `{"type":"directory","name":"c"}`},
+		{"/files/a/x", 403, ""},
+		{"/files/a/y", 200, "root/a/y"},
+		{"/files/b/c/y", 403, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.url, nil))
+		if rec.Code != test.code {
+			t.Errorf("%s: expected status %d, got %d", test.url, test.code, rec.Code)
+			continue
+		}
+		if actual := strings.TrimSpace(rec.Body.String()); test.expected != "" && actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.url, actual, test.expected)
+		}
+	}
+}
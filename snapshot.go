@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// SaveSnapshot writes the trees as JSON, like PrintJSON, with the metadata
+// compared by Diff, and the SHA-256 of regular files when hash is true. The
+// report holds the depth the trees were visited to.
+func SaveSnapshot(w io.Writer, opts *Options, trees Nodes, hash bool) error {
+	sopts := *opts
+	sopts.ByteSize, sopts.FileMode, sopts.LastMod = true, true, true
+	// the columns given would leave those out
+	sopts.Columns = nil
+	var doc []interface{}
+	report := NewReport(0, 0)
+	report.Depth = opts.DeepLevel
+	for _, node := range trees {
+		e := node.Entry(&sopts)
+		if hash {
+			addSHA256(node, e)
+		}
+		doc = append(doc, e)
+		d, f := countEntries(e)
+		report.Directories, report.Files = report.Directories+d, report.Files+f
+	}
+	doc = append(doc, report)
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// countEntries counts the directories and files under e.
+func countEntries(e *Entry) (dirs, files int) {
+	for _, child := range e.Contents {
+		if child.Type == "directory" {
+			dirs++
+		} else {
+			files++
+		}
+		d, f := countEntries(child)
+		dirs, files = dirs+d, files+f
+	}
+	return
+}
+
+func addSHA256(node *Node, e *Entry) {
+	if node.FileInfo != nil && node.Mode().IsRegular() {
+		if sum, err := fileSHA256(node.path); err == nil {
+			e.SHA256 = hex.EncodeToString(sum)
+		}
+	}
+	for i, nnode := range node.nodes {
+		addSHA256(nnode, e.Contents[i])
+	}
+}
+
+// LoadSnapshot reads trees written by SaveSnapshot, or by the JSON output,
+// and the depth they were visited to, 0 when unlimited or unknown.
+func LoadSnapshot(r io.Reader) (trees []*Entry, depth int, err error) {
+	var entries []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, 0, err
+	}
+	for _, raw := range entries {
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, 0, err
+		}
+		if e.Type != "report" {
+			trees = append(trees, &e)
+			continue
+		}
+		var report Report
+		if err := json.Unmarshal(raw, &report); err != nil {
+			return nil, 0, err
+		}
+		depth = report.Depth
+	}
+	return trees, depth, nil
+}
+
+// EntryFS is a filesystem holding trees in their structured form, so that
+// a snapshot can be visited like the directories it was taken from.
+type EntryFS struct {
+	entries map[string]*Entry
+}
+
+// NewEntryFS returns an EntryFS holding the trees, each under the path of
+// its root.
+func NewEntryFS(trees []*Entry) *EntryFS {
+	fs := &EntryFS{entries: make(map[string]*Entry)}
+	for _, e := range trees {
+		fs.add(filepath.Clean(e.Name), e)
+	}
+	return fs
+}
+
+func (fs *EntryFS) add(path string, e *Entry) {
+	fs.entries[path] = e
+	for _, child := range e.Contents {
+		fs.add(filepath.Join(path, filepath.Base(child.Name)), child)
+	}
+}
+
+// Stat a path
+func (fs *EntryFS) Stat(path string) (os.FileInfo, error) {
+	e, ok := fs.entries[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	if e.Type == "error" {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: errors.New(e.Error)}
+	}
+	return entryInfo{e}, nil
+}
+
+// ReadDir reads a directory
+func (fs *EntryFS) ReadDir(path string) ([]string, error) {
+	e, ok := fs.entries[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	if e.Error != "" {
+		return nil, &os.PathError{Op: "open", Path: path, Err: errors.New(e.Error)}
+	}
+	names := make([]string, 0, len(e.Contents))
+	for _, child := range e.Contents {
+		names = append(names, filepath.Base(child.Name))
+	}
+	return names, nil
+}
+
+// entryInfo is the os.FileInfo of an Entry, which is returned by Sys.
+type entryInfo struct{ *Entry }
+
+func (fi entryInfo) Name() string     { return filepath.Base(fi.Entry.Name) }
+func (fi entryInfo) IsDir() bool      { return fi.Type == "directory" }
+func (fi entryInfo) Sys() interface{} { return fi.Entry }
+
+func (fi entryInfo) Size() int64 {
+	if fi.Entry.Size == nil {
+		return 0
+	}
+	return *fi.Entry.Size
+}
+
+func (fi entryInfo) ModTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, fi.Time)
+	return t
+}
+
+var entryTypeModes = map[string]os.FileMode{
+	"directory": os.ModeDir,
+	"link":      os.ModeSymlink,
+	"fifo":      os.ModeNamedPipe,
+	"socket":    os.ModeSocket,
+	"char":      os.ModeDevice | os.ModeCharDevice,
+	"block":     os.ModeDevice,
+	"door":      os.ModeIrregular,
+}
+
+func (fi entryInfo) Mode() os.FileMode {
+	mode := entryTypeModes[fi.Type]
+	m, _ := strconv.ParseUint(fi.Entry.Mode, 8, 32)
+	mode |= os.FileMode(m).Perm()
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// nodeSHA256 returns the SHA-256 of a file, as saved in its snapshot for
+// the nodes of an EntryFS.
+func nodeSHA256(node *Node) ([]byte, error) {
+	if e, ok := node.Sys().(*Entry); ok {
+		if e.SHA256 == "" {
+			return nil, errors.New("no hash in snapshot")
+		}
+		return hex.DecodeString(e.SHA256)
+	}
+	return fileSHA256(node.path)
+}
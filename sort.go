@@ -1,6 +1,13 @@
 package main
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 func (n Nodes) Len() int      { return len(n) }
 func (n Nodes) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
@@ -52,7 +59,228 @@ func VerSort(f1, f2 os.FileInfo) bool {
 	return NaturalLess(f1.Name(), f2.Name())
 }
 
-func isdigit(b byte) bool { return '0' <= b && b <= '9' }
+func ExtSort(f1, f2 os.FileInfo) bool {
+	if f1 == nil || f2 == nil {
+		return f2 == nil
+	}
+	return filepath.Ext(f1.Name()) < filepath.Ext(f2.Name())
+}
+
+func InodeSort(f1, f2 os.FileInfo) bool {
+	if f1 == nil || f2 == nil {
+		return f2 == nil
+	}
+	_, i1, _, _, _ := getStat(f1)
+	_, i2, _, _, _ := getStat(f2)
+	return i1 < i2
+}
+
+// CountSort orders nodes by their number of visited children, so files
+// (which have none) come before directories.
+func CountSort(n1, n2 *Node) bool {
+	return len(n1.nodes) < len(n2.nodes)
+}
+
+// SortKey is one key of a multi-key sort, see ParseSortKeys.
+type SortKey struct {
+	Name    string
+	Reverse bool
+	// Collation is one of CollationNames, for the keys comparing names.
+	Collation string
+}
+
+// SortKeyNames lists the keys accepted by ParseSortKeys.
+var SortKeyNames = []string{
+	"dirsfirst", "ext", "name", "version", "natural",
+	"size", "mtime", "ctime", "atime", "inode", "count",
+}
+
+func byInfo(fn SortFunc) func(n1, n2 *Node) bool {
+	return func(n1, n2 *Node) bool { return fn(n1.FileInfo, n2.FileInfo) }
+}
+
+var sortKeyFuncs = map[string]func(n1, n2 *Node) bool{
+	"dirsfirst": byInfo(DirSort),
+	"ext":       byInfo(ExtSort),
+	"name":      byInfo(NameSort),
+	"version":   byInfo(VerSort),
+	"natural":   byInfo(VerSort),
+	"size":      byInfo(SizeSort),
+	"mtime":     byInfo(ModSort),
+	"ctime":     byInfo(CTimeSort),
+	"atime":     byInfo(ATimeSort),
+	"inode":     byInfo(InodeSort),
+	"count":     CountSort,
+}
+
+// collatedSortFuncs are the sort keys comparing strings, which accept a
+// collation.
+var collatedSortFuncs = map[string]func(cmp func(a, b string) int) SortFunc{
+	"name": func(cmp func(a, b string) int) SortFunc {
+		return func(f1, f2 os.FileInfo) bool {
+			if f1 == nil || f2 == nil {
+				return f2 == nil
+			}
+			return cmp(f1.Name(), f2.Name()) < 0
+		}
+	},
+	"ext": func(cmp func(a, b string) int) SortFunc {
+		return func(f1, f2 os.FileInfo) bool {
+			if f1 == nil || f2 == nil {
+				return f2 == nil
+			}
+			return cmp(filepath.Ext(f1.Name()), filepath.Ext(f2.Name())) < 0
+		}
+	},
+	"version": naturalSort,
+	"natural": naturalSort,
+}
+
+func naturalSort(cmp func(a, b string) int) SortFunc {
+	return func(f1, f2 os.FileInfo) bool {
+		if f1 == nil || f2 == nil {
+			return f2 == nil
+		}
+		return naturalCompare(f1.Name(), f2.Name(), cmp) < 0
+	}
+}
+
+func (key SortKey) less() func(n1, n2 *Node) bool {
+	if key.Collation == "" {
+		return sortKeyFuncs[key.Name]
+	}
+	return byInfo(collatedSortFuncs[key.Name](collation(key.Collation)))
+}
+
+// ParseSortKeys parses a comma-separated list of sort keys such as
+// "dirsfirst,ext,name". A key prefixed with '-' is sorted in reverse, and
+// the keys comparing names take an optional collation, as in "name:fold".
+func ParseSortKeys(s string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var key SortKey
+		if strings.HasPrefix(name, "-") {
+			name, key.Reverse = name[1:], true
+		}
+		key.Name, key.Collation, _ = strings.Cut(name, ":")
+		if _, ok := sortKeyFuncs[key.Name]; !ok {
+			return nil, fmt.Errorf("sort type '%s' not valid, should be one of: %s",
+				key.Name, strings.Join(SortKeyNames, ","))
+		}
+		if key.Collation != "" {
+			if _, ok := collatedSortFuncs[key.Name]; !ok {
+				return nil, fmt.Errorf("sort type '%s' does not take a collation", key.Name)
+			}
+			if !contains(CollationNames, key.Collation) {
+				return nil, fmt.Errorf("collation '%s' not valid, should be one of: %s",
+					key.Collation, strings.Join(CollationNames, ","))
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ByKeys sorts nodes by each key in turn, falling to the next key only
+// when the previous ones consider two nodes equal. Use it with
+// sort.Stable so that nodes equal on every key keep their order.
+type ByKeys struct {
+	Nodes
+	Keys []SortKey
+	fns  []func(n1, n2 *Node) bool
+}
+
+func (b *ByKeys) Less(i, j int) bool {
+	if b.fns == nil {
+		for _, key := range b.Keys {
+			b.fns = append(b.fns, key.less())
+		}
+	}
+	for k, key := range b.Keys {
+		n1, n2 := b.Nodes[i], b.Nodes[j]
+		if key.Reverse {
+			n1, n2 = n2, n1
+		}
+		if less, greater := b.fns[k](n1, n2), b.fns[k](n2, n1); less != greater {
+			return less
+		}
+	}
+	return false
+}
+
+// digitValue returns the value of a decimal digit of any script, or -1.
+func digitValue(r rune) int {
+	if r < utf8.RuneSelf {
+		if '0' <= r && r <= '9' {
+			return int(r - '0')
+		}
+		return -1
+	}
+	if !unicode.IsDigit(r) {
+		return -1
+	}
+	// Decimal digits come in contiguous runs starting at zero, which the
+	// ranges of the Nd table never split.
+	for _, rng := range unicode.Nd.R16 {
+		if lo, hi := rune(rng.Lo), rune(rng.Hi); lo <= r && r <= hi {
+			return int(r-lo) % 10
+		}
+	}
+	for _, rng := range unicode.Nd.R32 {
+		if lo, hi := rune(rng.Lo), rune(rng.Hi); lo <= r && r <= hi {
+			return int(r-lo) % 10
+		}
+	}
+	return -1
+}
+
+// splitDigits splits s after its leading run of digits, or of non-digits
+// when digits is false.
+func splitDigits(s string, digits bool) (run, rest string) {
+	for i, r := range s {
+		if (digitValue(r) >= 0) != digits {
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+// compareNumbers compares two runs of digits numerically, using the number
+// of leading zeros as a tie-breaker, so e.g. "2" < "02".
+func compareNumbers(nr1, nr2 string) int {
+	var v1, v2 []int
+	var zeros1, zeros2 int
+	for _, r := range nr1 {
+		if d := digitValue(r); d != 0 || len(v1) > 0 {
+			v1 = append(v1, d)
+		} else {
+			zeros1++
+		}
+	}
+	for _, r := range nr2 {
+		if d := digitValue(r); d != 0 || len(v2) > 0 {
+			v2 = append(v2, d)
+		} else {
+			zeros2++
+		}
+	}
+	// If lengths of numbers with non-zero prefix differ, the shorter one is
+	// less.
+	if len(v1) != len(v2) {
+		return len(v1) - len(v2)
+	}
+	for i := range v1 {
+		if v1[i] != v2[i] {
+			return v1[i] - v2[i]
+		}
+	}
+	// Otherwise, the one with less zeros is less.
+	return zeros1 - zeros2
+}
 
 // NaturalLess compares two strings using natural ordering. This means that e.g.
 // "abc2" < "abc12".
@@ -61,56 +289,42 @@ func isdigit(b byte) bool { return '0' <= b && b <= '9' }
 // compared bytewise, while the latter are compared numerically (except that
 // the number of leading zeros is used as a tie-breaker, so e.g. "2" < "02")
 //
-// Limitation: only ASCII digits (0-9) are considered.
-// Code taken from:
+// Decimal digits of any script are considered, so "٣" (Arabic-Indic three)
+// equals "3" numerically.
+// Originally taken from:
 // https://github.com/fvbommel/util/blob/master/sortorder/natsort.go
 func NaturalLess(str1, str2 string) bool {
-	idx1, idx2 := 0, 0
-	for idx1 < len(str1) && idx2 < len(str2) {
-		c1, c2 := str1[idx1], str2[idx2]
-		dig1, dig2 := isdigit(c1), isdigit(c2)
+	return naturalCompare(str1, str2, strings.Compare) < 0
+}
+
+// naturalCompare is NaturalLess as a three-way comparison, with the
+// non-digit sequences compared by cmp.
+func naturalCompare(str1, str2 string, cmp func(a, b string) int) int {
+	for str1 != "" && str2 != "" {
+		r1, _ := utf8.DecodeRuneInString(str1)
+		r2, _ := utf8.DecodeRuneInString(str2)
+		dig1, dig2 := digitValue(r1) >= 0, digitValue(r2) >= 0
+		var run1, run2 string
+		run1, str1 = splitDigits(str1, dig1)
+		run2, str2 = splitDigits(str2, dig2)
 		switch {
 		case dig1 != dig2: // Digits before other characters.
-			return dig1 // True if LHS is a digit, false if the RHS is one.
+			if dig1 {
+				return -1
+			}
+			return 1
 		case !dig1: // && !dig2, because dig1 == dig2
-			// UTF-8 compares bytewise-lexicographically, no need to decode
-			// codepoints.
-			if c1 != c2 {
-				return c1 < c2
+			if c := cmp(run1, run2); c != 0 {
+				return c
 			}
-			idx1++
-			idx2++
 		default: // Digits
-			// Eat zeros.
-			for ; idx1 < len(str1) && str1[idx1] == '0'; idx1++ {
-			}
-			for ; idx2 < len(str2) && str2[idx2] == '0'; idx2++ {
-			}
-			// Eat all digits.
-			nonZero1, nonZero2 := idx1, idx2
-			for ; idx1 < len(str1) && isdigit(str1[idx1]); idx1++ {
-			}
-			for ; idx2 < len(str2) && isdigit(str2[idx2]); idx2++ {
-			}
-			// If lengths of numbers with non-zero prefix differ, the shorter
-			// one is less.
-			if len1, len2 := idx1-nonZero1, idx2-nonZero2; len1 != len2 {
-				return len1 < len2
-			}
-			// If they're not equal, string comparison is correct.
-			if nr1, nr2 := str1[nonZero1:idx1], str2[nonZero2:idx2]; nr1 != nr2 {
-				return nr1 < nr2
-			}
-			// Otherwise, the one with less zeros is less.
-			// Because everything up to the number is equal, comparing the index
-			// after the zeros is sufficient.
-			if nonZero1 != nonZero2 {
-				return nonZero1 < nonZero2
+			if c := compareNumbers(run1, run2); c != 0 {
+				return c
 			}
 		}
 		// They're identical so far, so continue comparing.
 	}
 	// So far they are identical. At least one is ended. If the other continues,
 	// it sorts last.
-	return len(str1) < len(str2)
+	return len(str1) - len(str2)
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+var naturalTests = []struct {
+	str1, str2 string
+	expected   bool
+}{
+	{"abc2", "abc12", true},
+	{"abc12", "abc2", false},
+	{"2", "02", true},
+	{"a1", "ab", true},
+	{"file٣", "file12", true}, // Arabic-Indic three
+	{"file٣", "file3", false},
+	{"file3", "file٣", false},
+	{"file０９", "file10", true}, // fullwidth digits
+}
+
+func TestNaturalLess(t *testing.T) {
+	for _, test := range naturalTests {
+		if actual := NaturalLess(test.str1, test.str2); actual != test.expected {
+			t.Errorf("NaturalLess(%q, %q) = %v, expected %v", test.str1, test.str2, actual, test.expected)
+		}
+	}
+}
+
+var collationTests = []struct {
+	collation  string
+	str1, str2 string
+	expected   int
+}{
+	{"", "Zebra", "apple", -1},
+	{"fold", "Zebra", "apple", 1},
+	{"fold", "APPLE", "apple", 0},
+	{"", "caf\u00e9", "cafe\u0301", 1},
+	{"norm", "caf\u00e9", "cafe\u0301", 0},
+	{"fold", "CAF\u00c9", "cafe\u0301", 0},
+}
+
+func TestCollation(t *testing.T) {
+	for _, test := range collationTests {
+		actual := collation(test.collation)(test.str1, test.str2)
+		if actual < 0 {
+			actual = -1
+		} else if actual > 0 {
+			actual = 1
+		}
+		if actual != test.expected {
+			t.Errorf("%q: compare(%q, %q) = %d, expected %d", test.collation, test.str1, test.str2, actual, test.expected)
+		}
+	}
+}
+
+func TestLocaleCollation(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_COLLATE", "sv_SE.UTF-8")
+	cmp := collation("locale")
+	// in Swedish 'ö' sorts after 'z'
+	if cmp("öl", "zebra") <= 0 {
+		t.Errorf("expected ö to sort after z in the sv_SE locale")
+	}
+	t.Setenv("LC_COLLATE", "de_DE.UTF-8")
+	cmp = collation("locale")
+	if cmp("öl", "zebra") >= 0 {
+		t.Errorf("expected ö to sort before z in the de_DE locale")
+	}
+}
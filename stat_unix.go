@@ -19,3 +19,12 @@ func getStat(fi os.FileInfo) (ok bool, inode, device, uid, gid uint64) {
 	}
 	return true, uint64(stat.Ino), uint64(stat.Dev), uint64(stat.Uid), uint64(stat.Gid)
 }
+
+// linkCount returns the number of hard links to the file.
+func linkCount(fi os.FileInfo) (uint64, bool) {
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Nlink), true
+}
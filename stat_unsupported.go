@@ -8,3 +8,7 @@ import "os"
 func getStat(fi os.FileInfo) (ok bool, inode, device, uid, gid uint64) {
 	return false, 0, 0, 0, 0
 }
+
+func linkCount(fi os.FileInfo) (uint64, bool) {
+	return 0, false
+}
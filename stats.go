@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats is the extended report of trees: counts and sizes by extension
+// and by type, the largest files, the newest and oldest, and the deepest
+// entry.
+type Stats struct {
+	Extensions []*GroupStats `json:"extensions" xml:"extensions>extension"`
+	Types      []*GroupStats `json:"types" xml:"types>type"`
+	Largest    []*FileStats  `json:"largest" xml:"largest>file"`
+	Newest     *FileStats    `json:"newest,omitempty" xml:"newest,omitempty"`
+	Oldest     *FileStats    `json:"oldest,omitempty" xml:"oldest,omitempty"`
+	Deepest    *FileStats    `json:"deepest,omitempty" xml:"deepest,omitempty"`
+}
+
+// GroupStats are the number and total size of the entries of a group.
+type GroupStats struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Count int    `json:"count" xml:"count,attr"`
+	Size  int64  `json:"size" xml:"size,attr"`
+}
+
+// FileStats describes an entry of Stats.
+type FileStats struct {
+	Path  string `json:"path" xml:"path,attr"`
+	Size  int64  `json:"size" xml:"size,attr"`
+	Time  string `json:"time" xml:"time,attr"`
+	Depth int    `json:"depth" xml:"depth,attr"`
+	mtime time.Time
+}
+
+// noExtension is the extension group of the files without one.
+const noExtension = "(none)"
+
+// NewStats returns the stats of the trees, with the top largest files,
+// none when top is negative.
+// Extensions are those of the regular files, whose links to files listed
+// before are left out with the HardLinks option.
+func NewStats(opts *Options, trees Nodes, top int) *Stats {
+	s := new(Stats)
+	extensions := make(map[string]*GroupStats)
+	types := make(map[string]*GroupStats)
+	var files []*FileStats
+	add := func(groups map[string]*GroupStats, name string, size int64) {
+		g, ok := groups[name]
+		if !ok {
+			g = &GroupStats{Name: name}
+			groups[name] = g
+		}
+		g.Count++
+		g.Size += size
+	}
+	var walk func(node *Node)
+	walk = func(node *Node) {
+		if node.FileInfo == nil || opts.isCountedOnce(node) {
+			return
+		}
+		f := &FileStats{Path: node.path, Depth: node.depth, mtime: node.ModTime()}
+		f.Time = f.mtime.Format(time.RFC3339)
+		if !node.IsDir() {
+			f.Size = node.Size()
+		}
+		// the roots are not counted, as in the report
+		if node.depth != 0 {
+			add(types, node.Type(), f.Size)
+		}
+		if !node.IsDir() && node.Mode().IsRegular() {
+			ext := strings.ToLower(filepath.Ext(node.Name()))
+			if ext == "" || ext == node.Name() {
+				ext = noExtension
+			}
+			add(extensions, ext, f.Size)
+			files = append(files, f)
+		}
+		if s.Deepest == nil || node.depth > s.Deepest.Depth {
+			s.Deepest = f
+		}
+		for _, nnode := range node.nodes {
+			walk(nnode)
+		}
+	}
+	for _, node := range trees {
+		walk(node)
+	}
+	s.Extensions, s.Types = sortGroups(extensions), sortGroups(types)
+
+	for _, f := range files {
+		if s.Newest == nil || f.mtime.After(s.Newest.mtime) {
+			s.Newest = f
+		}
+		if s.Oldest == nil || f.mtime.Before(s.Oldest.mtime) {
+			s.Oldest = f
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	if top < 0 {
+		top = 0
+	}
+	if len(files) > top {
+		files = files[:top]
+	}
+	s.Largest = files
+	return s
+}
+
+// sortGroups returns the groups by decreasing size, then by name.
+func sortGroups(groups map[string]*GroupStats) []*GroupStats {
+	sorted := make([]*GroupStats, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Size != sorted[j].Size {
+			return sorted[i].Size > sorted[j].Size
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// table returns the stats as tables, as printed in the footer.
+func (s *Stats) table(opts *Options) string {
+	size := func(n int64) string {
+		if opts.UnitSize {
+			return formatBytes(n)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+	widen := func(width *int, s string) {
+		if len(s) > *width {
+			*width = len(s)
+		}
+	}
+	b := new(strings.Builder)
+	groups := func(title string, groups []*GroupStats) {
+		width, cwidth, swidth := len(title), len("count"), len("size")
+		for _, g := range groups {
+			widen(&width, g.Name)
+			widen(&cwidth, strconv.Itoa(g.Count))
+			widen(&swidth, size(g.Size))
+		}
+		fmt.Fprintf(b, "%-*s  %*s  %*s\n", width, title, cwidth, "count", swidth, "size")
+		for _, g := range groups {
+			fmt.Fprintf(b, "%-*s  %*d  %*s\n", width, g.Name, cwidth, g.Count, swidth, size(g.Size))
+		}
+	}
+	groups("extension", s.Extensions)
+	fmt.Fprintln(b)
+	groups("type", s.Types)
+
+	if len(s.Largest) > 0 {
+		var swidth int
+		for _, f := range s.Largest {
+			widen(&swidth, size(f.Size))
+		}
+		fmt.Fprintf(b, "\nlargest\n")
+		for _, f := range s.Largest {
+			fmt.Fprintf(b, "  %*s  %s\n", swidth, size(f.Size), f.Path)
+		}
+	}
+	fmt.Fprintln(b)
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	if s.Newest != nil {
+		fmt.Fprintf(w, "newest\t%s\t%s\n", s.Newest.mtime.Format("2006-01-02 15:04"), s.Newest.Path)
+		fmt.Fprintf(w, "oldest\t%s\t%s\n", s.Oldest.mtime.Format("2006-01-02 15:04"), s.Oldest.Path)
+	}
+	if s.Deepest != nil {
+		fmt.Fprintf(w, "deepest\tdepth %d\t%s\n", s.Deepest.Depth, s.Deepest.Path)
+	}
+	w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a.go", size: 100, lastMod: day(2)},
+			{name: "b.GO", size: 50, lastMod: day(5)},
+			{name: "Makefile", size: 10, lastMod: day(1)},
+			{name: "sub", files: []*file{
+				{name: "deep", files: []*file{
+					{name: "c.txt", size: 200, lastMod: day(3)},
+				}},
+			}},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b}
+	tr.Visit(opts)
+	stats := NewStats(opts, Nodes{tr}, 2)
+	expected := `extension  count  size
+.txt           1   200
+.go            2   150
+(none)         1    10
+
+type       count  size
+file           4   360
+directory      2     0
+
+largest
+  200  root/sub/deep/c.txt
+  100  root/a.go
+
+newest   2024-01-05 12:00  root/b.GO
+oldest   2024-01-01 12:00  root/Makefile
+deepest  depth 3           root/sub/deep/c.txt`
+	if actual := stats.table(opts); actual != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", actual, expected)
+	}
+
+	j, err := json.Marshal(stats.Types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := string(j); actual != `[{"name":"file","count":4,"size":360},{"name":"directory","count":2,"size":0}]` {
+		t.Errorf("wrong JSON: %s", actual)
+	}
+
+	if stats := NewStats(opts, Nodes{tr}, -1); len(stats.Largest) != 0 {
+		t.Errorf("expected no largest files, got %d", len(stats.Largest))
+	}
+}
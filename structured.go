@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is the structured form of a Node, as written by the JSON (-J) and
+// XML (-X) outputs. Metadata fields are only set when the matching option
+// is. ErrorKind is one of ErrStat, ErrUnreadable and ErrBrokenLink, and
+// Incomplete is set on the directories whose visit was cancelled. The
+// values of the other Columns are in Columns, and in Attrs for XML.
+type Entry struct {
+	// XMLName is the element name in XML, the same as Type.
+	XMLName    xml.Name          `json:"-"`
+	Type       string            `json:"type" xml:"-"`
+	Name       string            `json:"name" xml:"name,attr"`
+	Target     string            `json:"target,omitempty" xml:"target,attr,omitempty"`
+	Inode      *uint64           `json:"inode,omitempty" xml:"inode,attr,omitempty"`
+	Dev        *uint64           `json:"dev,omitempty" xml:"dev,attr,omitempty"`
+	Mode       string            `json:"mode,omitempty" xml:"mode,attr,omitempty"`
+	Prot       string            `json:"prot,omitempty" xml:"prot,attr,omitempty"`
+	User       string            `json:"user,omitempty" xml:"user,attr,omitempty"`
+	Group      string            `json:"group,omitempty" xml:"group,attr,omitempty"`
+	Size       *int64            `json:"size,omitempty" xml:"size,attr,omitempty"`
+	Time       string            `json:"time,omitempty" xml:"time,attr,omitempty"`
+	SHA256     string            `json:"sha256,omitempty" xml:"sha256,attr,omitempty"`
+	Line       string            `json:"line,omitempty" xml:"line,attr,omitempty"`
+	Error      string            `json:"error,omitempty" xml:"error,omitempty"`
+	ErrorKind  string            `json:"error_kind,omitempty" xml:"error_kind,attr,omitempty"`
+	Incomplete bool              `json:"incomplete,omitempty" xml:"incomplete,attr,omitempty"`
+	Columns    map[string]string `json:"columns,omitempty" xml:"-"`
+	Attrs      []xml.Attr        `json:"-" xml:",any,attr"`
+	Contents   []*Entry          `json:"contents,omitempty" xml:",any"`
+}
+
+// Report is the structured form of the footer.
+type Report struct {
+	XMLName     xml.Name `json:"-" xml:"report"`
+	Type        string   `json:"type" xml:"-"`
+	Directories int      `json:"directories" xml:"directories"`
+	Files       int      `json:"files" xml:"files"`
+	// HardLinks are the files left out of Files as links to files
+	// listed before
+	HardLinks int `json:"hard_links,omitempty" xml:"hard_links,omitempty"`
+	// Lines are the line counts of the text files, with --lines
+	Lines *LineCounts `json:"lines,omitempty" xml:"lines,omitempty"`
+	// Stats is the extended report, with --stats
+	Stats *Stats `json:"stats,omitempty" xml:"stats,omitempty"`
+	// Errors is only set when there are some
+	Errors *ErrorCounts `json:"errors,omitempty" xml:"errors,omitempty"`
+	// Incomplete tells why the visit was cancelled, if it was
+	Incomplete string `json:"incomplete,omitempty" xml:"incomplete,omitempty"`
+	// Depth is the -L level of the trees of a snapshot, 0 when unlimited
+	Depth int `json:"depth,omitempty" xml:"-"`
+}
+
+// NewReport returns the report of the given directory and file counts.
+func NewReport(dirs, files int) *Report {
+	return &Report{Type: "report", Directories: dirs, Files: files}
+}
+
+// footer returns the text of the report, as printed after the tree.
+func (r *Report) footer(opts *Options) string {
+	footer := fmt.Sprintf("%d directories", r.Directories)
+	if !opts.DirsOnly {
+		footer += fmt.Sprintf(", %d files", r.Files)
+	}
+	if r.HardLinks == 1 {
+		footer += ", 1 hard link"
+	} else if r.HardLinks > 0 {
+		footer += fmt.Sprintf(", %d hard links", r.HardLinks)
+	}
+	if r.Lines != nil {
+		footer += "\n" + r.Lines.String()
+	}
+	if r.Errors != nil {
+		footer += "\n" + r.Errors.String()
+	}
+	if r.Incomplete != "" {
+		footer += "\nincomplete, " + r.Incomplete
+	}
+	if r.Stats != nil {
+		footer += "\n\n" + r.Stats.table(opts)
+	}
+	return footer
+}
+
+// entryColumns are the columns held by fields of Entry.
+var entryColumns = map[string]bool{
+	"type": true, "target": true, "error": true, "inode": true, "device": true,
+	"mode": true, "prot": true, "user": true, "gid": true, "group": true,
+	"size": true, "mtime": true, "line": true,
+}
+
+// Entry returns the structured form of the node and its children.
+func (node *Node) Entry(opts *Options) *Entry {
+	e := &Entry{Type: node.Type(), Name: node.path}
+	e.XMLName.Local = e.Type
+	if node.depth != 0 && !opts.FullPath {
+		e.Name = filepath.Base(node.path)
+	}
+	e.Error, e.ErrorKind = node.errorText(), node.errorKind()
+	e.Incomplete = node.incomplete
+	if node.FileInfo == nil {
+		return e
+	}
+	if node.Mode()&os.ModeSymlink != 0 {
+		e.Target, _ = os.Readlink(node.path)
+	}
+	ok, inode, device, uid, gid := getStat(node)
+	if ok && opts.showColumn("inode") {
+		e.Inode = &inode
+	}
+	if ok && opts.showColumn("device") {
+		e.Dev = &device
+	}
+	if opts.showColumn("mode") {
+		e.Mode = fmt.Sprintf("%04o", unixMode(node.Mode()))
+	}
+	if opts.showColumn("prot") {
+		e.Prot = node.Mode().String()
+	}
+	if ok && opts.showColumn("user") {
+		e.User = userName(uid)
+	}
+	if ok && opts.showColumn("group") {
+		e.Group = groupName(gid)
+	} else if ok && opts.showColumn("gid") {
+		e.Group = fmt.Sprint(gid)
+	}
+	if opts.showColumn("size") {
+		size := node.Size()
+		if node.IsDir() {
+			size, _ = dirRecursiveSize(opts, node)
+		}
+		e.Size = &size
+	}
+	if opts.showColumn("mtime") {
+		e.Time = node.ModTime().Format(time.RFC3339Nano)
+	}
+	if opts.showColumn("line") && !node.IsDir() {
+		if line, hasMore, ok := node.firstLine(); ok {
+			e.Line = line
+			if hasMore {
+				e.Line += "…"
+			}
+		}
+	}
+	for _, c := range opts.columns() {
+		if entryColumns[c.Name()] {
+			continue
+		}
+		if value, ok := c.Value(opts, node); ok {
+			if e.Columns == nil {
+				e.Columns = make(map[string]string)
+			}
+			e.Columns[c.Name()] = value
+			e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: c.Name()}, Value: value})
+		}
+	}
+	for _, nnode := range node.nodes {
+		e.Contents = append(e.Contents, nnode.Entry(opts))
+	}
+	return e
+}
+
+// unixMode returns the permission bits of mode, along with the setuid,
+// setgid and sticky bits, as in chmod.
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// PrintJSON prints the trees, followed by the report unless it is nil, as
+// a JSON array.
+func PrintJSON(opts *Options, trees Nodes, report *Report) error {
+	var doc []interface{}
+	for _, node := range trees {
+		doc = append(doc, node.Entry(opts))
+	}
+	if report != nil {
+		doc = append(doc, report)
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(opts.OutFile, "%s\n", b)
+	return err
+}
+
+// xmlTree is the document written by PrintXML.
+type xmlTree struct {
+	XMLName xml.Name `xml:"tree"`
+	Entries []*Entry `xml:",any"`
+	Report  *Report
+}
+
+// PrintXML prints the trees, followed by the report unless it is nil, as
+// an XML document.
+func PrintXML(opts *Options, trees Nodes, report *Report) error {
+	doc := xmlTree{Report: report}
+	for _, node := range trees {
+		doc.Entries = append(doc.Entries, node.Entry(opts))
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(opts.OutFile, "%s%s\n", xml.Header, b)
+	return err
+}
+
+// structuredRenderer renders the trees as a JSON or XML document, once they
+// are whole.
+type structuredRenderer struct {
+	treeCollector
+	opts   *Options
+	xml    bool
+	report *Report
+}
+
+func (r *structuredRenderer) Report(report *Report) error {
+	r.report = report
+	return nil
+}
+
+func (r *structuredRenderer) Flush() error {
+	if r.xml {
+		return PrintXML(r.opts, r.trees, r.report)
+	}
+	return PrintJSON(r.opts, r.trees, r.report)
+}
+
+func init() {
+	RegisterRenderer("json", func(opts *Options) Renderer { return &structuredRenderer{opts: opts} })
+	RegisterRenderer("xml", func(opts *Options) Renderer { return &structuredRenderer{opts: opts, xml: true} })
+}
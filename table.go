@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TableFormats are the formats of the table output: comma separated values
+// with a quote when needed, tab separated values with tabs, newlines and
+// backslashes escaped by a backslash, and values each followed by a NUL, as
+// in find -print0.
+var TableFormats = []string{"csv", "tsv", "nul"}
+
+// tableRow returns the values of the columns for the node.
+func tableRow(opts *Options, columns []Column, node *Node) []string {
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		if value, ok := c.Value(opts, node); ok {
+			row[i] = value
+		}
+	}
+	return row
+}
+
+// tableWriter writes the rows of a table in one of TableFormats.
+type tableWriter interface {
+	Write(row []string) error
+	Flush()
+	Error() error
+}
+
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+type delimWriter struct {
+	*bufio.Writer
+	// sep follows each value, replaced by end after the last one when set
+	sep, end string
+	escape   func(string) string
+	err      error
+}
+
+func (w *delimWriter) Write(row []string) error {
+	for i, value := range row {
+		if w.escape != nil {
+			value = w.escape(value)
+		}
+		sep := w.sep
+		if i == len(row)-1 && w.end != "" {
+			sep = w.end
+		}
+		if _, err := w.WriteString(value + sep); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *delimWriter) Flush() {
+	if err := w.Writer.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *delimWriter) Error() error { return w.err }
+
+func newTableWriter(w io.Writer, format string) (tableWriter, error) {
+	switch format {
+	case "csv":
+		return csv.NewWriter(w), nil
+	case "tsv":
+		return &delimWriter{Writer: bufio.NewWriter(w), sep: "\t", end: "\n", escape: tsvEscaper.Replace}, nil
+	case "nul":
+		return &delimWriter{Writer: bufio.NewWriter(w), sep: "\x00"}, nil
+	}
+	return nil, fmt.Errorf("table format '%s' not valid, should be one of: %s",
+		format, strings.Join(TableFormats, ","))
+}
+
+// PrintTable prints a row with the columns of each node of the trees,
+// after a row with the names of the columns when header is true.
+func PrintTable(opts *Options, format string, columns []Column, header bool, trees Nodes) error {
+	topts := *opts
+	topts.Table, topts.NoHeader, topts.Columns = format, !header, columns
+	r := newTableRenderer(&topts)
+	for _, node := range trees {
+		if err := Render(&topts, node, r); err != nil {
+			return err
+		}
+	}
+	return r.Flush()
+}
+
+// defaultTableColumns are the columns of the table renderer without
+// opts.Columns.
+const defaultTableColumns = "path,type,size"
+
+// tableRenderer renders a row per node as it is walked, in the format of
+// opts.Table.
+type tableRenderer struct {
+	opts    *Options
+	columns []Column
+	w       tableWriter
+	err     error
+	// started is set once the header is written
+	started bool
+}
+
+func newTableRenderer(opts *Options) *tableRenderer {
+	r := &tableRenderer{opts: opts, columns: opts.Columns}
+	if r.columns == nil {
+		r.columns, _ = ParseColumns(defaultTableColumns)
+	}
+	format := opts.Table
+	if format == "" {
+		format = TableFormats[0]
+	}
+	r.w, r.err = newTableWriter(opts.OutFile, format)
+	return r
+}
+
+// start writes the header, before the first row.
+func (r *tableRenderer) start() {
+	if r.started {
+		return
+	}
+	r.started = true
+	if r.opts.NoHeader {
+		return
+	}
+	names := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		names[i] = c.Name()
+	}
+	r.w.Write(names)
+}
+
+func (r *tableRenderer) row(node *Node) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.start()
+	return r.w.Write(tableRow(r.opts, r.columns, node))
+}
+
+func (r *tableRenderer) BeginDir(node *Node) error { return r.row(node) }
+
+func (r *tableRenderer) EndDir(node *Node) error { return nil }
+
+func (r *tableRenderer) Entry(node *Node) error { return r.row(node) }
+
+// Error renders the nodes that could not be stat'ed, the others having a
+// row already.
+func (r *tableRenderer) Error(node *Node, err error) error {
+	if node.FileInfo != nil {
+		return nil
+	}
+	return r.row(node)
+}
+
+// Report leaves out the report, which has no place in a table.
+func (r *tableRenderer) Report(report *Report) error { return nil }
+
+func (r *tableRenderer) Flush() error {
+	if r.err != nil {
+		return r.err
+	}
+	r.start()
+	r.w.Flush()
+	return r.w.Error()
+}
+
+func init() {
+	RegisterRenderer("table", func(opts *Options) Renderer { return newTableRenderer(opts) })
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTable(t *testing.T) {
+	mtime := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	root := &file{
+		name:    "root",
+		lastMod: mtime,
+		files: []*file{
+			{name: "a,\"b\"", size: 10, lastMod: mtime, stat: &syscall.Stat_t{Ino: 7, Uid: 1000, Gid: 100, Mode: 0644}},
+			{name: "c\td", size: 5, lastMod: mtime, stat: &syscall.Stat_t{Ino: 8, Mode: 0600}},
+			{name: "sub", lastMod: mtime, mode: 0755, files: []*file{}},
+		},
+	}
+	fs := NewFs().addFile(root.name, root)
+	columns, err := ParseColumns("path,depth,type,size,mode,uid,gid,inode,mtime,error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format   string
+		header   bool
+		expected string
+	}{
+		{"csv", true, `path,depth,type,size,mode,uid,gid,inode,mtime,error
+root,0,directory,15,0000,0,0,0,2023-05-01T10:00:00Z,
+"root/a,""b""",1,file,10,0644,1000,100,7,2023-05-01T10:00:00Z,
+root/c	d,1,file,5,0600,0,0,8,2023-05-01T10:00:00Z,
+root/sub,1,directory,0,0755,0,0,0,2023-05-01T10:00:00Z,
+`},
+		{"tsv", false, `root	0	directory	15	0000	0	0	0	2023-05-01T10:00:00Z	
+root/a,"b"	1	file	10	0644	1000	100	7	2023-05-01T10:00:00Z	
+root/c\td	1	file	5	0600	0	0	8	2023-05-01T10:00:00Z	
+root/sub	1	directory	0	0755	0	0	0	2023-05-01T10:00:00Z	
+`},
+	}
+	for _, test := range tests {
+		b := new(bytes.Buffer)
+		tr := New(root.name)
+		opts := &Options{Fs: fs, OutFile: b}
+		tr.Visit(opts)
+		if err := PrintTable(opts, test.format, columns, test.header, Nodes{tr}); err != nil {
+			t.Fatal(err)
+		}
+		if actual := b.String(); actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.format, actual, test.expected)
+		}
+	}
+
+	b := new(bytes.Buffer)
+	tr := New(root.name)
+	opts := &Options{Fs: fs, OutFile: b, DeepLevel: 1}
+	tr.Visit(opts)
+	columns, _ = ParseColumns("path,type")
+	PrintTable(opts, "nul", columns, false, Nodes{tr})
+	expected := "root\x00directory\x00root/a,\"b\"\x00file\x00root/c\td\x00file\x00root/sub\x00directory\x00"
+	if actual := b.String(); actual != expected {
+		t.Errorf("nul:\ngot:\n%q\nexpected:\n%q", actual, expected)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	if _, err := ParseColumns("path, size ,user"); err != nil {
+		t.Errorf("expected columns to be valid, got %v", err)
+	}
+	if _, err := ParseColumns("path,foo"); err == nil {
+		t.Error("expected column foo to be rejected")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TextRenderer renders trees as text, the default output of tree.
+type TextRenderer struct {
+	opts *Options
+	// dirs holds the directories being rendered
+	dirs []*textDir
+}
+
+type textDir struct {
+	indent string
+	// left is the number of entries left to render
+	left int
+}
+
+// NewTextRenderer returns a TextRenderer writing to opts.OutFile.
+func NewTextRenderer(opts *Options) *TextRenderer {
+	return &TextRenderer{opts: opts}
+}
+
+// BeginDir renders the directory.
+func (t *TextRenderer) BeginDir(node *Node) error {
+	indent := t.branch()
+	t.line(node)
+	dir := &textDir{indent: indent, left: len(node.nodes)}
+	if t.opts.ErrOut != nil {
+		// entries that could not be stat'ed are only reported
+		for _, nnode := range node.nodes {
+			if nnode.FileInfo == nil {
+				dir.left--
+			}
+		}
+	}
+	t.dirs = append(t.dirs, dir)
+	return nil
+}
+
+// EndDir ends the rendering of the directory.
+func (t *TextRenderer) EndDir(node *Node) error {
+	t.dirs = t.dirs[:len(t.dirs)-1]
+	return nil
+}
+
+// Entry renders the node.
+func (t *TextRenderer) Entry(node *Node) error {
+	t.branch()
+	t.line(node)
+	return nil
+}
+
+// Error renders the node which could not be stat'ed, or reports it to
+// opts.ErrOut when set. The errors of directories are rendered by
+// BeginDir.
+func (t *TextRenderer) Error(node *Node, err error) error {
+	if node.FileInfo != nil {
+		return nil
+	}
+	if t.opts.ErrOut != nil {
+		fmt.Fprintf(t.opts.ErrOut, "tree: %s: %s\n", node.path, node.errorText())
+		return nil
+	}
+	t.branch()
+	t.line(node)
+	return nil
+}
+
+// Report renders the footer.
+func (t *TextRenderer) Report(report *Report) error {
+	_, err := fmt.Fprintf(t.opts.OutFile, "\n%s\n", report.footer(t.opts))
+	return err
+}
+
+// branch writes the branch leading to the next entry of the current
+// directory, and returns the indentation of its contents.
+func (t *TextRenderer) branch() string {
+	if len(t.dirs) == 0 {
+		return ""
+	}
+	dir := t.dirs[len(t.dirs)-1]
+	dir.left--
+	if t.opts.NoIndent {
+		return dir.indent
+	}
+	if dir.left == 0 {
+		fmt.Fprintf(t.opts.OutFile, dir.indent+"└── ")
+		return dir.indent + "    "
+	}
+	fmt.Fprintf(t.opts.OutFile, dir.indent+"├── ")
+	return dir.indent + "│   "
+}
+
+// line writes the line of the node.
+func (t *TextRenderer) line(node *Node) {
+	opts := t.opts
+	if node.err != nil && opts.ErrOut == nil {
+		err := errMessage(node.err)
+		name := node.path
+		if !opts.FullPath {
+			name = filepath.Base(name)
+		}
+		fmt.Fprintf(opts.OutFile, "%s [%s]\n", name, err)
+		return
+	}
+	var props []string
+	for _, c := range opts.textColumns() {
+		var value string
+		var ok bool
+		if tc, isText := c.(TextColumn); isText {
+			value, ok = tc.Text(opts, node)
+		} else {
+			value, ok = c.Value(opts, node)
+		}
+		if ok {
+			props = append(props, value)
+		}
+	}
+	if len(props) > 0 {
+		fmt.Fprintf(opts.OutFile, "[%s]  ", strings.Join(props, " "))
+	}
+	// name/path
+	var name string
+	if node.depth == 0 || opts.FullPath {
+		name = node.path
+	} else {
+		name = node.Name()
+	}
+	// Quotes
+	if opts.Quotes {
+		name = fmt.Sprintf("\"%s\"", name)
+	}
+	// Colorize
+	if opts.Colorize {
+		name = opts.color(node, name)
+	}
+	// Classify
+	if opts.Classify && node.depth != 0 {
+		name += node.classify()
+	}
+	// IsSymlink
+	if node.Mode()&os.ModeSymlink == os.ModeSymlink {
+		vtarget, err := os.Readlink(node.path)
+		if err != nil {
+			vtarget = node.path
+		}
+		targetPath, err := filepath.EvalSymlinks(node.path)
+		if err != nil {
+			targetPath = vtarget
+		}
+		fi, _ := opts.Fs.Stat(targetPath)
+		if opts.Colorize && fi != nil {
+			vtarget = opts.color(&Node{FileInfo: fi, path: vtarget}, vtarget)
+		}
+		name = fmt.Sprintf("%s -> %s", name, vtarget)
+		if node.recursive {
+			name += " [recursive, not followed]"
+		}
+	}
+	// Hard links
+	if opts.HardLinks != nil {
+		if target, ok := opts.HardLinks.Target(node.path); ok {
+			name += fmt.Sprintf(" [hardlink to %s]", target)
+		}
+	}
+	// Mount points
+	if opts.isMount(node) {
+		if fsType, ok := opts.fsType(node); ok {
+			name += fmt.Sprintf(" [mount point, %s]", fsType)
+		} else {
+			name += " [mount point]"
+		}
+	}
+	// Cancelled visit
+	if node.incomplete {
+		name += " [incomplete]"
+	}
+	// Print file name/details
+	// the main idea of the print logic came from here: github.com/campoy/tools/tree
+	fmt.Fprint(opts.OutFile, name)
+
+	// Print first line of content
+	if opts.Contents {
+		if line, hasMore, ok := node.firstLine(); ok {
+			if hasMore {
+				fmt.Fprintf(opts.OutFile, " => `%s…`", line)
+			} else {
+				fmt.Fprintf(opts.OutFile, " => `%s`", line)
+			}
+		}
+	}
+	fmt.Fprintln(opts.OutFile, "")
+	if opts.ErrOut != nil && node.errorKind() != "" {
+		fmt.Fprintf(opts.ErrOut, "tree: %s: %s\n", node.path, node.errorText())
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ParseSkeleton parses the output of tree, as text in any charset, JSON or
+// XML, into the trees it describes.
+func ParseSkeleton(r io.Reader) ([]*Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	switch trimmed := bytes.TrimSpace(data); {
+	// the text output starts with "[" too with -s, -p and the like
+	case bytes.HasPrefix(trimmed, []byte("[")) && json.Valid(trimmed):
+		err = json.Unmarshal(trimmed, &entries)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		var doc xmlTree
+		err = xml.Unmarshal(trimmed, &doc)
+		entries = doc.Entries
+		setXMLTypes(entries)
+	default:
+		entries, err = parseText(string(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	// drop the report
+	trees := entries[:0]
+	for _, e := range entries {
+		if e.Type != "report" {
+			trees = append(trees, e)
+		}
+	}
+	return trees, nil
+}
+
+func setXMLTypes(entries []*Entry) {
+	for _, e := range entries {
+		e.Type = e.XMLName.Local
+		setXMLTypes(e.Contents)
+	}
+}
+
+var (
+	ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	textMount  = regexp.MustCompile(` \[mount point(, [^\]]*)?\]$`)
+	textReport = regexp.MustCompile(`^\d+ director(y|ies)(, \d+ files?)?(, \d+ hard links?)?$`)
+)
+
+// Prefixes of an entry in the text output, in the UTF-8 and ASCII charsets:
+// the indentation of each ancestor, then the branch leading to the entry.
+var (
+	textIndents  = []string{"│   ", "|   ", "    "}
+	textBranches = []string{"├── ", "└── ", "|-- ", "`-- ", "+-- ", "\\-- "}
+)
+
+func parseText(data string) ([]*Entry, error) {
+	var trees []*Entry
+	var stack []*Entry // the ancestors of the current line
+	for i, line := range strings.Split(data, "\n") {
+		line = ansiEscape.ReplaceAllString(strings.TrimRight(line, "\r"), "")
+		// the report ends the trees, and is followed by other lines of the
+		// footer: line counts, errors and stats
+		if textReport.MatchString(line) {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			stack = nil
+			continue
+		}
+		depth := 0
+		for indented := true; indented; {
+			indented = false
+			for _, indent := range textIndents {
+				if strings.HasPrefix(line, indent) {
+					line, depth, indented = line[len(indent):], depth+1, true
+					break
+				}
+			}
+		}
+		branched := false
+		for _, branch := range textBranches {
+			if strings.HasPrefix(line, branch) {
+				line, depth, branched = line[len(branch):], depth+1, true
+				break
+			}
+		}
+		e := parseTextEntry(line)
+		if !branched {
+			if depth != 0 {
+				return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+			}
+			trees = append(trees, e)
+			stack = []*Entry{e}
+			continue
+		}
+		if depth > len(stack) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", i+1)
+		}
+		parent := stack[depth-1]
+		parent.Type = "directory"
+		parent.Contents = append(parent.Contents, e)
+		stack = append(stack[:depth], e)
+	}
+	return trees, nil
+}
+
+// classifyTypes are the types of the entries by their -F indicator.
+var classifyTypes = map[byte]string{
+	'/': "directory", '*': "file", '|': "fifo", '=': "socket", '>': "door",
+}
+
+// parseTextEntry parses a line of the text output, without its prefixes,
+// e.g. "[-rw-r--r--]  name -> target".
+func parseTextEntry(s string) *Entry {
+	e := &Entry{Type: "file"}
+	// properties
+	if strings.HasPrefix(s, "[") {
+		if i := strings.Index(s, "]  "); i != -1 {
+			s = s[i+3:]
+		}
+	}
+	// first line of content
+	if i := strings.Index(s, " => `"); i != -1 && strings.HasSuffix(s, "`") {
+		e.Line = s[i+5 : len(s)-1]
+		s = s[:i]
+	}
+	// directories whose visit was cancelled
+	if strings.HasSuffix(s, " [incomplete]") {
+		e.Type = "directory"
+		s = strings.TrimSuffix(s, " [incomplete]")
+	}
+	// mount points, left unvisited with -x
+	if loc := textMount.FindStringIndex(s); loc != nil {
+		e.Type = "directory"
+		s = s[:loc[0]]
+	}
+	// hard links, to a file listed before
+	if i := strings.LastIndex(s, " [hardlink to "); i != -1 && strings.HasSuffix(s, "]") {
+		s = s[:i]
+	}
+	// symbolic links
+	if i := strings.Index(s, " -> "); i != -1 {
+		e.Type = "link"
+		e.Target = strings.TrimSuffix(s[i+4:], " [recursive, not followed]")
+		s = strings.TrimSuffix(s[:i], "@")
+	}
+	// indicators of -F, or directories given with a trailing slash
+	if len(s) > 1 {
+		if typ, ok := classifyTypes[s[len(s)-1]]; ok {
+			e.Type = typ
+			s = s[:len(s)-1]
+		}
+	}
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		s = s[1 : len(s)-1]
+	}
+	e.Name = s
+	return e
+}
+
+// CreateSkeleton creates the contents of the trees under dir: directories,
+// and files that are empty or hold their first line when it is known.
+// Links are skipped and existing files are left untouched. With dryRun,
+// the paths that would be created are printed to out instead.
+func CreateSkeleton(dir string, trees []*Entry, dryRun bool, out io.Writer) error {
+	if !dryRun {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, e := range trees {
+		if e.Type == "directory" || len(e.Contents) > 0 {
+			if err := createEntries(dir, e.Contents, dryRun, out); err != nil {
+				return err
+			}
+		} else if err := createEntries(dir, []*Entry{e}, dryRun, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createEntries(dir string, entries []*Entry, dryRun bool, out io.Writer) error {
+	for _, e := range entries {
+		// full paths (-f) keep only the name
+		name := filepath.Base(e.Name)
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			return fmt.Errorf("invalid name %q", e.Name)
+		}
+		path := filepath.Join(dir, name)
+		fi, err := os.Lstat(path)
+		exists := err == nil
+		switch {
+		case e.Type == "link" || e.Error != "":
+			continue
+		case e.Type == "directory" || len(e.Contents) > 0:
+			// never create files through an existing link
+			if exists && !fi.IsDir() {
+				return fmt.Errorf("%s exists and is not a directory", path)
+			}
+			if dryRun && !exists {
+				fmt.Fprintln(out, path+string(filepath.Separator))
+			} else if !dryRun {
+				if err := os.MkdirAll(path, 0755); err != nil {
+					return err
+				}
+			}
+			if err := createEntries(path, e.Contents, dryRun, out); err != nil {
+				return err
+			}
+		case exists:
+		case dryRun:
+			fmt.Fprintln(out, path)
+		default:
+			var content string
+			if line := strings.TrimSuffix(e.Line, "…"); line != "" {
+				content = line + "\n"
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
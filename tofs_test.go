@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fiatjaf/tree/ostree"
+)
+
+var skeletonTests = []struct {
+	name  string
+	input string
+}{
+	{"utf-8", `root
+├── [       1500]  a => ` + "`hello`" + `
+└── c
+    ├── "d"
+    └── e/
+
+1 directories, 2 files
+`},
+	{"ascii", `root
+|-- a
+` + "`" + `-- c
+    |-- d
+    ` + "`" + `-- e/
+`},
+	{"sizes", `[       4096]  root
+├── [       1500]  a*
+└── [       4096]  c/
+    ├── [          0]  d=
+    └── [       4096]  e/
+
+1 directories, 2 files
+`},
+	{"incomplete", `root [incomplete]
+├── a
+└── c [incomplete]
+    ├── d
+    └── e/
+
+1 directories, 2 files
+incomplete, timed out
+`},
+	{"modes", `[drwxr-xr-x]  root
+├── [-rwxr-xr-x]  "a"*
+└── [drwxr-xr-x]  "c"/
+    ├── [prw-r--r--]  "d"|
+    └── [drwxr-xr-x]  "e"/
+`},
+	{"json", `[
+  {"type": "directory", "name": "root", "contents": [
+    {"type": "file", "name": "a"},
+    {"type": "directory", "name": "c", "contents": [
+      {"type": "file", "name": "root/c/d"},
+      {"type": "directory", "name": "e"}
+    ]},
+    {"type": "link", "name": "l", "target": "a"}
+  ]},
+  {"type": "report", "directories": 2, "files": 2}
+]`},
+	{"xml", `<?xml version="1.0" encoding="UTF-8"?>
+<tree>
+  <directory name="root">
+    <file name="a"></file>
+    <directory name="c">
+      <file name="d"></file>
+      <directory name="e"></directory>
+    </directory>
+  </directory>
+  <report><directories>2</directories><files>2</files></report>
+</tree>`},
+}
+
+func TestSkeleton(t *testing.T) {
+	for _, test := range skeletonTests {
+		trees, err := ParseSkeleton(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		dir := t.TempDir()
+		b := new(bytes.Buffer)
+		if err := CreateSkeleton(dir, trees, true, b); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		expected := strings.Join([]string{
+			filepath.Join(dir, "a"),
+			filepath.Join(dir, "c") + "/",
+			filepath.Join(dir, "c", "d"),
+			filepath.Join(dir, "c", "e") + "/",
+		}, "\n") + "\n"
+		if b.String() != expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.name, b.String(), expected)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%s: dry run created files", test.name)
+		}
+	}
+}
+
+func TestSkeletonCreate(t *testing.T) {
+	trees, err := ParseSkeleton(strings.NewReader(skeletonTests[0].input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := CreateSkeleton(dir, trees, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "a")); err != nil || string(content) != "hello\n" {
+		t.Errorf("expected a to hold its first line, got %q (%v)", content, err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "c", "e")); err != nil || !fi.IsDir() {
+		t.Errorf("expected c/e to be a directory (%v)", err)
+	}
+}
+
+func TestSkeletonFooter(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "s"), 0755)
+	os.WriteFile(filepath.Join(src, "s", "a.go"), []byte("package a\n"), 0644)
+	os.Symlink("missing", filepath.Join(src, "l"))
+
+	// the output of tree -l --stats, with the line counts of --lines too
+	b := new(bytes.Buffer)
+	tr := New(src)
+	opts := &Options{Fs: new(ostree.FS), OutFile: b, FollowLink: true}
+	d, f := tr.Visit(opts)
+	tr.Print(opts)
+	lines := NewLineStats(false, false)
+	lines.Count(context.Background(), opts, Nodes{tr})
+	errs := new(ErrorCounts)
+	errs.Add(tr)
+	report := &Report{Directories: d, Files: f, Errors: errs, Lines: &lines.Total,
+		Stats: NewStats(opts, Nodes{tr}, 10)}
+	fmt.Fprintf(b, "\n%s\n", report.footer(opts))
+	if !strings.Contains(b.String(), "1 error: 1 broken link") {
+		t.Fatalf("expected the footer to list the broken link:\n%s", b)
+	}
+
+	trees, err := ParseSkeleton(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "dst")
+	out := new(bytes.Buffer)
+	if err := CreateSkeleton(dst, trees, true, out); err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(dst, "s") + "/\n" + filepath.Join(dst, "s", "a.go") + "\n"
+	if out.String() != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", out, expected)
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
+	"github.com/fiatjaf/tree/listtree"
 	"github.com/fiatjaf/tree/ostree"
 )
 
@@ -30,3 +32,95 @@ func TestTree(t *testing.T) {
 		t.Errorf("\nactual\n%s\n != expect\n%s\n", actual, expect)
 	}
 }
+
+func TestFromFile(t *testing.T) {
+	b := new(bytes.Buffer)
+	lfs := listtree.New()
+	if err := lfs.AddList(strings.NewReader("b/c.txt\na/\nb/d/e.txt\n"), '\n'); err != nil {
+		t.Fatal(err)
+	}
+	tr := New(".")
+	opts := &Options{
+		Fs:      lfs,
+		OutFile: b,
+	}
+	d, f := tr.Visit(opts)
+	tr.Print(opts)
+
+	actual := b.String()
+
+	expect := `.
+├── a
+└── b
+    ├── c.txt
+    └── d
+        └── e.txt
+`
+	if actual != expect {
+		t.Errorf("\nactual\n%s\n != expect\n%s\n", actual, expect)
+	}
+	if d != 3 || f != 2 {
+		t.Errorf("expect (dir, file) count to be equal to (3, 2), got (%d, %d)", d, f)
+	}
+}
+
+func TestFromFileParents(t *testing.T) {
+	b := new(bytes.Buffer)
+	lfs := listtree.New()
+	if err := lfs.AddList(strings.NewReader("a\n../b\n../../c\nd/../../e\n"), '\n'); err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{Fs: lfs, OutFile: b}
+	for _, root := range lfs.Roots() {
+		tr := New(root)
+		tr.Visit(opts)
+		tr.Print(opts)
+	}
+	// d/../../e is cleaned to ../e
+	expect := `.
+└── a
+..
+├── b
+└── e
+../..
+└── c
+`
+	if actual := b.String(); actual != expect {
+		t.Errorf("\nactual\n%s\n != expect\n%s\n", actual, expect)
+	}
+}
+
+func TestXML(t *testing.T) {
+	b := new(bytes.Buffer)
+	tr := New("ostree/testdata")
+	opts := &Options{
+		Fs:      new(ostree.FS),
+		OutFile: b,
+	}
+	d, f := tr.Visit(opts)
+	PrintXML(opts, Nodes{tr}, NewReport(d, f))
+
+	actual := b.String()
+
+	expect := `<?xml version="1.0" encoding="UTF-8"?>
+<tree>
+  <directory name="ostree/testdata">
+    <directory name="a">
+      <directory name="b">
+        <file name="b.txt"></file>
+      </directory>
+    </directory>
+    <directory name="c">
+      <file name="c.txt"></file>
+    </directory>
+  </directory>
+  <report>
+    <directories>3</directories>
+    <files>2</files>
+  </report>
+</tree>
+`
+	if actual != expect {
+		t.Errorf("\nactual\n%s\n != expect\n%s\n", actual, expect)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// Walker holds the functions called by Walk, any of which may be nil.
+//
+// Pre and Post may return filepath.SkipDir to skip the rest of the directory
+// holding the node, or the contents of the node itself when Pre returns it
+// for a directory, and filepath.SkipAll to stop the walk. Any other error stops
+// the walk and is returned by Walk.
+type Walker struct {
+	// Pre is called on each node that could be stat'ed, before its contents
+	Pre func(node *Node) error
+	// Post is called on each node passed to Pre, after its contents or
+	// right away when Pre skips them, unless Pre stops the walk
+	Post func(node *Node) error
+	// Error is called on each node whose Err is set, once it is known:
+	// right away for nodes that could not be stat'ed, which are not passed
+	// to Pre, and after Pre for directories that could not be read.
+	Error func(node *Node, err error) error
+}
+
+// Walk visits the tree rooted at path like Visit, with the same filtering,
+// depth and sorting, calling the functions of w on each node along the way.
+// Directories are read one at a time, and their contents are forgotten
+// once walked, so that memory use depends on the depth of the tree rather
+// than its size; the whole tree is visited first when pruning though, as
+// empty directories are only known at the end. The walk stops when ctx is
+// done, returning its error.
+func Walk(ctx context.Context, path string, opts *Options, w Walker) error {
+	root := New(path)
+	read := true
+	if opts.Prune {
+		root.VisitContext(ctx, opts)
+		read = false
+	} else {
+		root.FileInfo, root.err = opts.Fs.Stat(path)
+		if root.FileInfo != nil {
+			root.brokenLink = opts.isBrokenLink(root)
+		}
+	}
+	err := root.walk(ctx, opts, w, read, true)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		err = nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// walk calls the functions of w on the node and its contents, reading them
+// first when read is true, and forgetting them once walked when forget is
+// true. Directories are read, and links to one followed with the
+// FollowLink option, before Pre is called so that it knows their contents.
+func (node *Node) walk(ctx context.Context, opts *Options, w Walker, read, forget bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if node.FileInfo == nil {
+		return node.walkError(w)
+	}
+	// the contents of a followed link are visited whole
+	followed := node.follow(opts)
+	deeper := opts.DeepLevel == 0 || node.depth < opts.DeepLevel
+	if read && !followed && node.err == nil && node.IsDir() && deeper {
+		lopts := *opts
+		lopts.DeepLevel = node.depth + 1
+		node.visit(ctx, &lopts)
+	}
+	if w.Pre != nil {
+		if err := w.Pre(node); err == filepath.SkipDir {
+			// the contents of a directory, or the rest of the one holding
+			// the node, are skipped, but the node still ends with Post
+			if forget {
+				node.nodes = nil
+			}
+			if w.Post != nil {
+				if err := w.Post(node); err != nil {
+					return err
+				}
+			}
+			if node.IsDir() || followed {
+				return nil
+			}
+			return filepath.SkipDir
+		} else if err != nil {
+			return err
+		}
+	}
+	if node.err != nil {
+		// there are no contents to skip
+		if err := node.walkError(w); err != nil && err != filepath.SkipDir {
+			return err
+		}
+	}
+	for _, nnode := range node.nodes {
+		if err := nnode.walk(ctx, opts, w, read && !followed, forget); err == filepath.SkipDir {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if forget {
+		node.nodes = nil
+	}
+	if w.Post != nil {
+		return w.Post(node)
+	}
+	return nil
+}
+
+func (node *Node) walkError(w Walker) error {
+	if w.Error == nil {
+		return nil
+	}
+	return w.Error(node, node.err)
+}
+
+// Depth returns the depth of the node in its tree, 0 for the root.
+func (node *Node) Depth() int {
+	return node.depth
+}
+
+// Err returns the error met while visiting the node, if any.
+func (node *Node) Err() error {
+	return node.err
+}
@@ -0,0 +1,34 @@
+//go:build go1.23
+
+package main
+
+import (
+	"context"
+	"iter"
+	"path/filepath"
+)
+
+// All returns an iterator over the nodes of the tree rooted at path, as
+// passed to the Pre and Error functions of a Walker by Walk: the error is
+// set for the nodes that could not be read, so directories that could not
+// be read come twice. The iteration ends with the error of ctx when it is
+// done before the end of the walk.
+func All(ctx context.Context, path string, opts *Options) iter.Seq2[*Node, error] {
+	return func(yield func(*Node, error) bool) {
+		stopped := false
+		next := func(node *Node, err error) error {
+			if !yield(node, err) {
+				stopped = true
+				return filepath.SkipAll
+			}
+			return nil
+		}
+		err := Walk(ctx, path, opts, Walker{
+			Pre:   func(node *Node) error { return next(node, nil) },
+			Error: next,
+		})
+		if err != nil && !stopped {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//go:build go1.23
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "bad"},
+			{name: "locked", files: []*file{{name: "x"}}},
+			{name: "z", files: []*file{{name: "y"}}},
+		},
+	}
+	fs := &unreadableFs{NewFs().addFile(root.name, root), map[string]bool{"root/locked": true}}
+	var paths []string
+	for node, err := range All(context.Background(), root.name, &Options{Fs: fs}) {
+		if err != nil {
+			paths = append(paths, "!"+node.Path())
+		} else {
+			paths = append(paths, node.Path())
+		}
+	}
+	expected := "root,root/a,root/locked,!root/locked,root/z,root/z/y,!root/bad"
+	if actual := strings.Join(paths, ","); actual != expected {
+		t.Errorf("got %s, expected %s", actual, expected)
+	}
+
+	paths = nil
+	for node := range All(context.Background(), root.name, &Options{Fs: fs}) {
+		paths = append(paths, node.Path())
+		if node.Path() == "root/locked" {
+			break
+		}
+	}
+	if actual := strings.Join(paths, ","); actual != "root,root/a,root/locked" {
+		t.Errorf("expected the walk to stop at root/locked, got %s", actual)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for node, err := range All(ctx, root.name, &Options{Fs: fs}) {
+		if node != nil || err != context.Canceled {
+			t.Errorf("expected only the error of the context, got %v, %v", node, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// visited returns the paths of the node and its children, depth first.
+func visited(node *Node) []string {
+	paths := []string{node.path}
+	for _, nnode := range node.nodes {
+		paths = append(paths, visited(nnode)...)
+	}
+	return paths
+}
+
+func TestWalk(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", size: 5},
+			{name: "bad"},
+			{name: "b", files: []*file{
+				{name: "c", files: []*file{{name: "d", size: 1}}},
+				{name: "e"},
+			}},
+			{name: "locked", files: []*file{{name: "x"}}},
+		},
+	}
+	fs := &unreadableFs{NewFs().addFile(root.name, root), map[string]bool{"root/locked": true}}
+
+	// the same nodes as Visit
+	for _, opts := range []*Options{
+		{Fs: fs},
+		{Fs: fs, DeepLevel: 2},
+		{Fs: fs, Prune: true, Pattern: "d"},
+		{Fs: fs, ReverSort: true, DirsOnly: true},
+	} {
+		var walked []string
+		w := Walker{
+			Pre: func(node *Node) error {
+				walked = append(walked, node.path)
+				return nil
+			},
+			Error: func(node *Node, err error) error {
+				if node.FileInfo == nil {
+					walked = append(walked, node.path)
+				}
+				return nil
+			},
+		}
+		if err := Walk(context.Background(), root.name, opts, w); err != nil {
+			t.Fatal(err)
+		}
+		tr := New(root.name)
+		tr.Visit(opts)
+		if expected := strings.Join(visited(tr), ","); strings.Join(walked, ",") != expected {
+			t.Errorf("%+v: walked %v, expected %v", opts, walked, expected)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		pre      func(node *Node) error
+		expected string
+		err      error
+	}{
+		{"all", nil, "+root +root/a -root/a +root/b +root/b/c +root/b/c/d -root/b/c/d -root/b/c +root/b/e -root/b/e -root/b " +
+			"+root/locked !root/locked -root/locked !root/bad -root", nil},
+		{"skip dir", func(node *Node) error {
+			if node.path == "root/b" {
+				return filepath.SkipDir
+			}
+			return nil
+		}, "+root +root/a -root/a +root/b -root/b +root/locked !root/locked -root/locked !root/bad -root", nil},
+		{"skip read dir", func(node *Node) error {
+			if node.path == "root/b/c" {
+				return filepath.SkipDir
+			}
+			return nil
+		}, "+root +root/a -root/a +root/b +root/b/c -root/b/c +root/b/e -root/b/e -root/b " +
+			"+root/locked !root/locked -root/locked !root/bad -root", nil},
+		{"skip rest of dir", func(node *Node) error {
+			if node.path == "root/b/c/d" {
+				return filepath.SkipDir
+			}
+			return nil
+		}, "+root +root/a -root/a +root/b +root/b/c +root/b/c/d -root/b/c/d -root/b/c +root/b/e -root/b/e -root/b " +
+			"+root/locked !root/locked -root/locked !root/bad -root", nil},
+		{"stop", func(node *Node) error {
+			if node.path == "root/b/c" {
+				return filepath.SkipAll
+			}
+			return nil
+		}, "+root +root/a -root/a +root/b +root/b/c", nil},
+		{"error", func(node *Node) error {
+			if node.path == "root/b/e" {
+				return errors.New("stop here")
+			}
+			return nil
+		}, "+root +root/a -root/a +root/b +root/b/c +root/b/c/d -root/b/c/d -root/b/c +root/b/e", errors.New("stop here")},
+	}
+	for _, test := range tests {
+		var events []string
+		w := Walker{
+			Pre: func(node *Node) error {
+				events = append(events, "+"+node.path)
+				if test.pre != nil {
+					return test.pre(node)
+				}
+				return nil
+			},
+			Post: func(node *Node) error {
+				if node.nodes != nil {
+					t.Errorf("%s: contents of %s not forgotten", test.name, node.path)
+				}
+				events = append(events, "-"+node.path)
+				return nil
+			},
+			Error: func(node *Node, err error) error {
+				events = append(events, "!"+node.path)
+				return nil
+			},
+		}
+		err := Walk(context.Background(), root.name, &Options{Fs: fs}, w)
+		if actual := strings.Join(events, " "); actual != test.expected {
+			t.Errorf("%s:\ngot:\n%s\nexpected:\n%s", test.name, actual, test.expected)
+		}
+		if (err == nil) != (test.err == nil) || err != nil && err.Error() != test.err.Error() {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestWalkCancel(t *testing.T) {
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a", files: []*file{{name: "b"}}},
+			{name: "c"},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cfs := &cancellingFs{MockFs: NewFs().addFile(root.name, root), at: "root/a", cancel: cancel}
+	var walked []string
+	err := Walk(ctx, root.name, &Options{Fs: cfs}, Walker{Pre: func(node *Node) error {
+		walked = append(walked, node.path)
+		return nil
+	}})
+	if err != context.Canceled {
+		t.Errorf("expected the walk to be cancelled, got %v", err)
+	}
+	if strings.Join(walked, ",") != "root,root/a" {
+		t.Errorf("expected the walk to stop after root/a, got %v", walked)
+	}
+}
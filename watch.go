@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Watcher reports the changes of a filesystem, see ostree.FS.NewWatcher.
+type Watcher interface {
+	// Watch reports changes to the entries of a directory.
+	Watch(dir string) error
+	// Events returns the channel receiving the paths of changed entries.
+	Events() <-chan string
+	Close() error
+}
+
+const (
+	// watchDelay gathers the changes happening at once in a single redraw
+	watchDelay = 100 * time.Millisecond
+	// watchHighlight is how long changed entries stay highlighted
+	watchHighlight = 3 * time.Second
+)
+
+// watchDrawn is called after each draw of Watch, as a hook for tests.
+var watchDrawn = func() {}
+
+// Watch prints the trees of dirs, and prints them again in place each time
+// w reports a change, highlighting the changed entries for a few seconds.
+// Only the directories holding changed entries are read again. When the
+// output is a file rather than a terminal, it is rewritten on each change
+// instead, without highlights. It returns when ctx is done or w is closed.
+func Watch(ctx context.Context, dirs []string, opts *Options, w Watcher, report bool) error {
+	changed := make(map[string]time.Time)
+	wopts := *opts
+	clear := func() { fmt.Fprint(opts.OutFile, Escape+"[H"+Escape+"[2J") }
+	if f, ok := opts.OutFile.(*os.File); ok && !term.IsTerminal(int(f.Fd())) {
+		clear = func() {
+			if f.Truncate(0) == nil {
+				f.Seek(0, io.SeekStart)
+			}
+		}
+	} else {
+		wopts.Colorize = true
+		wopts.Color = func(node *Node, s string) string {
+			if t, ok := changed[node.path]; ok && time.Since(t) < watchHighlight {
+				return ANSIColorFormat("7", s)
+			}
+			if opts.Colorize {
+				return opts.color(node, s)
+			}
+			return s
+		}
+	}
+
+	trees := make(Nodes, len(dirs))
+	for i, dir := range dirs {
+		trees[i] = New(dir)
+		trees[i].Visit(&wopts)
+		if err := trees[i].watch(w); err != nil {
+			return err
+		}
+	}
+	draw := func() {
+		var nd, nf int
+		clear()
+		for _, inf := range trees {
+			d, f := inf.count()
+			nd, nf = nd+d, nf+f
+			inf.Print(&wopts)
+		}
+		if report {
+			fmt.Fprintf(opts.OutFile, "\n%s\n", NewReport(nd, nf).footer(opts))
+		}
+		watchDrawn()
+	}
+	draw()
+
+	// redraw once the changes settle, and again when highlights expire
+	stale := make(map[*Node]bool)
+	redraw := time.NewTimer(0)
+	<-redraw.C
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case path, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+			changed[path] = time.Now()
+			if node := trees.dirOf(path); node != nil {
+				stale[node] = true
+			}
+			redraw.Reset(watchDelay)
+		case <-redraw.C:
+			for node := range stale {
+				node.revisit(&wopts)
+				if err := node.watch(w); err != nil {
+					return err
+				}
+				delete(stale, node)
+			}
+			draw()
+			for path, t := range changed {
+				if time.Since(t) >= watchHighlight {
+					delete(changed, path)
+				}
+			}
+			if len(changed) > 0 {
+				redraw.Reset(watchHighlight)
+			}
+		}
+	}
+}
+
+// dirOf returns the deepest visited directory of the trees holding path.
+func (nodes Nodes) dirOf(path string) *Node {
+	for _, node := range nodes {
+		if node.FileInfo == nil || !node.IsDir() {
+			continue
+		}
+		rel, err := filepath.Rel(node.path, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if dir := node.nodes.dirOf(path); dir != nil {
+			return dir
+		}
+		return node
+	}
+	return nil
+}
+
+// revisit visits the node again, along with its children.
+func (node *Node) revisit(opts *Options) {
+	node.FileInfo, node.err, node.nodes = nil, nil, nil
+	node.incomplete, node.mount, node.brokenLink = false, false, false
+	node.visit(context.Background(), opts)
+}
+
+// count returns the number of directories and files under the node, as
+// returned by Visit.
+func (node *Node) count() (dirs, files int) {
+	for _, nnode := range node.nodes {
+		switch {
+		case nnode.FileInfo == nil:
+		case nnode.IsDir():
+			d, f := nnode.count()
+			dirs, files = dirs+d+1, files+f
+		default:
+			files++
+		}
+	}
+	return
+}
+
+// watch the node and its visited directories.
+func (node *Node) watch(w Watcher) error {
+	if node.err != nil || node.FileInfo == nil || !node.IsDir() {
+		return nil
+	}
+	// the directory may be gone already, the next redraw will tell
+	if err := w.Watch(node.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, nnode := range node.nodes {
+		if err := nnode.watch(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Mock watcher
+type fakeWatcher struct {
+	dirs   []string
+	events chan string
+}
+
+func (w *fakeWatcher) Watch(dir string) error {
+	w.dirs = append(w.dirs, dir)
+	return nil
+}
+func (w *fakeWatcher) Events() <-chan string { return w.events }
+func (w *fakeWatcher) Close() error          { return nil }
+
+func TestWatch(t *testing.T) {
+	defer out.clear()
+	root := &file{
+		name: "root",
+		files: []*file{
+			{name: "a"},
+			{name: "b", files: []*file{{name: "c"}}},
+		},
+	}
+	cfs := &countingFs{MockFs: fs.clean().addFile(root.name, root)}
+	w := &fakeWatcher{events: make(chan string)}
+	drawn := make(chan bool)
+	watchDrawn = func() { drawn <- true }
+	defer func() { watchDrawn = func() {} }()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, []string{"root"}, &Options{Fs: cfs, OutFile: out}, w, true)
+	}()
+	<-drawn
+	cfs.reads = nil
+	root.files[1].files = append(root.files[1].files, &file{name: "d"})
+	cfs.addFile("root/b/d", root.files[1].files[1])
+	w.events <- "root/b/d"
+	<-drawn
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cfs.reads, ",") != "root/b" {
+		t.Errorf("expected only root/b to be read again, got %v", cfs.reads)
+	}
+	if strings.Join(w.dirs, ",") != "root,root/b,root/b" {
+		t.Errorf("expected root and root/b to be watched, and root/b again, got %v", w.dirs)
+	}
+	clear := Escape + "[H" + Escape + "[2J"
+	expected := clear + `root
+├── a
+└── b
+    └── c
+
+1 directories, 2 files
+` + clear + `root
+├── a
+└── b
+    ├── c
+    └── ` + ANSIColorFormat("7", "d") + `
+
+1 directories, 3 files
+`
+	if !out.equal(expected) {
+		t.Errorf("got:\n%q\nexpected:\n%q", out.str, expected)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	root := &file{name: "root", files: []*file{{name: "a"}}}
+	fs.clean().addFile(root.name, root)
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := &fakeWatcher{events: make(chan string)}
+	drawn := make(chan bool)
+	watchDrawn = func() { drawn <- true }
+	defer func() { watchDrawn = func() {} }()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, []string{"root"}, &Options{Fs: fs, OutFile: f}, w, false)
+	}()
+	<-drawn
+	root.files = append(root.files, &file{name: "b"})
+	fs.addFile("root/b", root.files[1])
+	w.events <- "root/b"
+	<-drawn
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the file holds the last tree only, as plain text
+	if expected := "root\n├── a\n└── b\n"; string(content) != expected {
+		t.Errorf("got:\n%q\nexpected:\n%q", content, expected)
+	}
+}